@@ -1,33 +1,156 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/sifes/kpi-3-lab3/painter"
 	"github.com/sifes/kpi-3-lab3/painter/lang"
 	"github.com/sifes/kpi-3-lab3/ui"
+	"github.com/sifes/kpi-3-lab3/ui/headless"
+)
+
+var (
+	headlessMode = flag.Bool("headless", false, "render into memory without opening a window")
+	outFile      = flag.String("out", "", "in headless mode, save the last frame as PNG to this file on exit")
+	maxFPS       = flag.Int("fps", 60, "maximum number of frames per second, 0 disables the limit")
+	sceneFile    = flag.String("scene", "", "restore the scene from this file on start and save it back on exit")
+	sceneDir     = flag.String("scenes", ".", "directory used by the save and load commands")
+	queueSize    = flag.Int("queue", 1024, "maximum number of pending operations, 0 means unbounded")
+	overflow     painter.OverflowPolicy
+	canvas       = canvasSize(painter.DefaultCanvasSize)
 )
 
 func main() {
+	flag.TextVar(&overflow, "overflow", painter.OverflowReject,
+		"what to do when the queue is full: block, reject, drop-oldest or drop-non-update")
+	if env := os.Getenv("PAINTER_SIZE"); env != "" {
+		if err := canvas.Set(env); err != nil {
+			log.Fatalf("Invalid PAINTER_SIZE: %s", err)
+		}
+	}
+	flag.Var(&canvas, "size", "canvas size as WIDTHxHEIGHT, defaults to $PAINTER_SIZE or 800x800")
+	flag.Parse()
+
 	var (
 		pv ui.Visualizer // Візуалізатор створює вікно та малює у ньому.
 
 		// Потрібні для частини 2.
 		opLoop painter.Loop // Цикл обробки команд.
 		parser lang.Parser  // Парсер команд.
+		assets lang.AssetStore
 	)
 
-	//pv.Debug = true
-	pv.Title = "Simple painter"
+	opLoop.MaxFPS = *maxFPS
+	opLoop.CanvasSize = image.Point(canvas)
+	parser.CanvasSize = image.Point(canvas)
+	opLoop.MsgQueue.Capacity = *queueSize
+	opLoop.MsgQueue.Policy = overflow
+	parser.SceneDir = *sceneDir
+	parser.Assets = &assets
 
-	pv.OnScreenReady = opLoop.Start
-	opLoop.Receiver = &pv
+	// Операції, додані до запуску циклу подій, залишаються у черзі, тож сцену можна відновити одразу.
+	restoreScene(&opLoop, &parser)
 
 	go func() {
 		http.Handle("/", lang.HttpHandler(&opLoop, &parser))
+		http.Handle("/snapshot.png", lang.SnapshotHandler(&opLoop))
+		http.Handle("/undo", lang.CommandHandler(&opLoop, &parser, "undo"))
+		http.Handle("/redo", lang.CommandHandler(&opLoop, &parser, "redo"))
+		http.Handle("/ws", lang.WebSocketHandler(&opLoop, &parser))
+		http.Handle("/assets/{name}", lang.AssetHandler(&assets))
 		_ = http.ListenAndServe("localhost:17000", nil)
 	}()
 
+	if *headlessMode {
+		runHeadless(&opLoop)
+		saveScene(&parser)
+		return
+	}
+
+	//pv.Debug = true
+	pv.Title = "Simple painter"
+	pv.Width, pv.Height = canvas.X, canvas.Y
+
+	pv.OnScreenReady = opLoop.Start
+	opLoop.Receiver = &pv
+
 	pv.Main()
 	opLoop.StopAndWait()
+	saveScene(&parser)
+}
+
+// restoreScene завантажує сцену з файлу, заданого прапорцем -scene, і відправляє її на відображення.
+func restoreScene(opLoop *painter.Loop, parser *lang.Parser) {
+	if *sceneFile == "" {
+		return
+	}
+	if err := parser.LoadScene(*sceneFile); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Failed to restore the scene: %s", err)
+		}
+		return
+	}
+	ops, err := parser.Parse(strings.NewReader("update"))
+	if err != nil {
+		log.Printf("Failed to restore the scene: %s", err)
+		return
+	}
+	if err := opLoop.Post(painter.OperationList(ops)); err != nil {
+		log.Printf("Failed to restore the scene: %s", err)
+	}
+}
+
+// saveScene зберігає сцену у файл, заданий прапорцем -scene.
+func saveScene(parser *lang.Parser) {
+	if *sceneFile == "" {
+		return
+	}
+	if err := parser.SaveScene(*sceneFile); err != nil {
+		log.Printf("Failed to save the scene: %s", err)
+	}
+}
+
+// runHeadless виконує цикл подій без вікна до отримання сигналу завершення.
+func runHeadless(opLoop *painter.Loop) {
+	var rec headless.Recorder
+	opLoop.Receiver = &rec
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := opLoop.Run(ctx, headless.Screen{}); err != nil {
+		log.Printf("Failed to run the event loop: %s", err)
+		return
+	}
+
+	if *outFile != "" {
+		if err := rec.SavePNG(*outFile); err != nil {
+			log.Printf("Failed to save the last frame: %s", err)
+		}
+	}
+}
+
+// canvasSize розмір полотна, який задається прапорцем у форматі WIDTHxHEIGHT.
+type canvasSize image.Point
+
+func (c *canvasSize) String() string {
+	return fmt.Sprintf("%dx%d", c.X, c.Y)
+}
+
+func (c *canvasSize) Set(s string) error {
+	var w, h int
+	if n, err := fmt.Sscanf(s, "%dx%d", &w, &h); err != nil || n != 2 || w <= 0 || h <= 0 {
+		return fmt.Errorf("invalid canvas size %q, expected WIDTHxHEIGHT", s)
+	}
+	*c = canvasSize{X: w, Y: h}
+	return nil
 }
@@ -0,0 +1,133 @@
+package painter
+
+import (
+	"image"
+	"math"
+	"time"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Easing перетворює частку часу анімації t з діапазону [0, 1] у частку пройденого шляху.
+type Easing func(t float64) float64
+
+// Linear рівномірний рух.
+func Linear(t float64) float64 {
+	return t
+}
+
+// EaseIn рух з поступовим прискоренням.
+func EaseIn(t float64) float64 {
+	return t * t
+}
+
+// EaseOut рух з поступовим сповільненням.
+func EaseOut(t float64) float64 {
+	return t * (2 - t)
+}
+
+// EaseInOut рух з прискоренням на початку та сповільненням наприкінці.
+func EaseInOut(t float64) float64 {
+	if t < 0.5 {
+		return 2 * t * t
+	}
+	return -1 + (4-2*t)*t
+}
+
+// Bounce рух з відскоками наприкінці, як у м'яча, що падає на підлогу.
+func Bounce(t float64) float64 {
+	const n, d = 7.5625, 2.75
+	switch {
+	case t < 1/d:
+		return n * t * t
+	case t < 2/d:
+		t -= 1.5 / d
+		return n*t*t + 0.75
+	case t < 2.5/d:
+		t -= 2.25 / d
+		return n*t*t + 0.9375
+	default:
+		t -= 2.625 / d
+		return n*t*t + 0.984375
+	}
+}
+
+// DefaultAnimationFPS кількість кадрів анімації за секунду, якщо Animation.FPS не задано.
+const DefaultAnimationFPS = 60
+
+// Animation плавно переміщує фігури з ідентифікатором ID на X, Y пікселів за час Duration.
+type Animation struct {
+	X, Y     int
+	ID       string
+	Duration time.Duration
+	Easing   Easing // закон руху, за замовчуванням Linear
+	FPS      int    // кількість кадрів за секунду, за замовчуванням DefaultAnimationFPS
+}
+
+// Frames розбиває анімацію, яка починається в момент start, на операції сцени stage. Фігури вважаються вже
+// переміщеними у кінцеве положення: операція begin, яка має виконатися перед сценою в момент start, відсуває їх
+// назад на X, Y, а кадри поступово повертають їх у кінцеве положення, перемальовують поточну сцену stage
+// і сигналізують про готовність текстури. Зміщення округлюються від початку анімації, тож після останнього кадру
+// фігури зміщені рівно на X, Y.
+//
+// frames — операція Scheduled першого кадру. Цикл подій після виконання кожного кадру сам планує наступний,
+// пропускаючи кадри, час яких вже минув, тож анімація будь-якої тривалості займає в черзі одну операцію.
+func (a *Animation) Frames(stage *Stage, start time.Time) (begin Operation, frames *Scheduled) {
+	anim := *a
+	if anim.Easing == nil {
+		anim.Easing = Linear
+	}
+	fps := anim.FPS
+	if fps <= 0 {
+		fps = DefaultAnimationFPS
+	}
+	n := int((int64(anim.Duration)*int64(fps) + int64(time.Second) - 1) / int64(time.Second))
+	if n < 1 {
+		n = 1
+	}
+
+	first := &animationFrame{a: &anim, stage: stage, start: start, n: n, i: 1}
+	return &stageOffset{stage: stage, id: a.ID, d: image.Pt(-a.X, -a.Y)}, &Scheduled{At: first.at(), Op: first}
+}
+
+// offset повертає зміщення фігур після кадру i з n.
+func (a *Animation) offset(i, n int) image.Point {
+	k := a.Easing(float64(i) / float64(n))
+	return image.Pt(int(math.Round(float64(a.X)*k)), int(math.Round(float64(a.Y)*k)))
+}
+
+// animationFrame кадр i з n анімації a, яка почалася в момент start. Попередній виконаний кадр має номер prev.
+type animationFrame struct {
+	a       *Animation
+	stage   *Stage
+	start   time.Time
+	n       int
+	prev, i int
+}
+
+func (f *animationFrame) Do(t screen.Texture) bool {
+	op := stageOffset{stage: f.stage, id: f.a.ID, d: f.a.offset(f.i, f.n).Sub(f.a.offset(f.prev, f.n)), redraw: true}
+	return op.Do(t)
+}
+
+// at повертає момент, у який має виконатися кадр.
+func (f *animationFrame) at() time.Time {
+	return f.start.Add(f.a.Duration * time.Duration(f.i) / time.Duration(f.n))
+}
+
+// next повертає наступний кадр, який потрібно виконати після цього в момент now, або nil, якщо кадр останній.
+// Кадри, час яких вже минув, пропускаються.
+func (f *animationFrame) next(now time.Time) *Scheduled {
+	if f.i >= f.n {
+		return nil
+	}
+	i := f.i + 1
+	if f.a.Duration > 0 {
+		// Перший кадр, час якого ще не настав.
+		if due := int(int64(now.Sub(f.start))*int64(f.n)/int64(f.a.Duration)) + 1; due > i {
+			i = min(due, f.n)
+		}
+	}
+	next := &animationFrame{a: f.a, stage: f.stage, start: f.start, n: f.n, prev: f.i, i: i}
+	return &Scheduled{At: next.at(), Op: next}
+}
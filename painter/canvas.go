@@ -0,0 +1,85 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"sync"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/draw"
+)
+
+// canvas обгортає текстуру циклу подій і дублює всі зміни у копію в пам'яті, щоб вміст текстури
+// можна було прочитати (текстури shiny не дозволяють читати пікселі). Екран, який створив текстуру,
+// дозволяє створити сумісний з нею буфер, який операції використовують повторно.
+type canvas struct {
+	screen.Texture
+	img    *image.RGBA
+	screen screen.Screen
+	buf    screen.Buffer // буфер розміром з текстуру, створюється під час першого звернення
+}
+
+func newCanvas(t screen.Texture, s screen.Screen) *canvas {
+	return &canvas{Texture: t, img: image.NewRGBA(image.Rectangle{Max: t.Size()}), screen: s}
+}
+
+// buffer повертає буфер розміром з текстуру. Вміст буфера поза областю, яку заповнила сама операція,
+// не визначений.
+func (c *canvas) buffer() (screen.Buffer, error) {
+	if c.buf == nil {
+		buf, err := c.screen.NewBuffer(c.Size())
+		if err != nil {
+			return nil, err
+		}
+		c.buf = buf
+	}
+	return c.buf, nil
+}
+
+func (c *canvas) Release() {
+	if c.buf != nil {
+		c.buf.Release()
+		c.buf = nil
+	}
+	c.Texture.Release()
+}
+
+func (c *canvas) pixels() *image.RGBA {
+	return c.img
+}
+
+func (c *canvas) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	c.Texture.Upload(dp, src, sr)
+	draw.Draw(c.img, sr.Sub(sr.Min).Add(dp), src.RGBA(), sr.Min, draw.Src)
+}
+
+func (c *canvas) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	c.Texture.Fill(dr, src, op)
+	draw.Draw(c.img, dr, &image.Uniform{C: src}, image.Point{}, op)
+}
+
+// snapshot зберігає копію кадру, який востаннє було відправлено у Receiver.
+type snapshot struct {
+	mu  sync.Mutex
+	img *image.RGBA
+}
+
+func (s *snapshot) store(src *image.RGBA) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.img == nil || s.img.Rect != src.Rect {
+		s.img = image.NewRGBA(src.Rect)
+	}
+	copy(s.img.Pix, src.Pix)
+}
+
+func (s *snapshot) load() *image.RGBA {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.img == nil {
+		return nil
+	}
+	img := image.NewRGBA(s.img.Rect)
+	copy(img.Pix, s.img.Pix)
+	return img
+}
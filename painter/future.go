@@ -0,0 +1,123 @@
+package painter
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// ErrOperationDropped повертається з Future, якщо операцію відкинуто з черги через її переповнення.
+var ErrOperationDropped = errors.New("painter: operation dropped from the queue")
+
+// Future дозволяє дочекатися виконання операції, доданої через Loop.Submit.
+type Future struct {
+	once      sync.Once
+	done      chan struct{}
+	published bool
+	err       error
+
+	// Поля нижче використовуються лише з горутини циклу подій.
+	parts int  // частини операції, які ще не виконано: сама операція та частини, відкладені через Scheduled
+	ready bool // одна з виконаних частин підготувала кадр
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+// Done повертає канал, який закривається, коли операцію виконано або вона вже не буде виконана.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Published повідомляє, чи було після виконання операції відправлено кадр у Receiver. Значення має сенс лише після
+// закриття Done.
+func (f *Future) Published() bool {
+	select {
+	case <-f.done:
+		return f.published
+	default:
+		return false
+	}
+}
+
+// Err повертає причину, з якої операцію не було виконано, або nil. Значення має сенс лише після закриття Done.
+func (f *Future) Err() error {
+	select {
+	case <-f.done:
+		return f.err
+	default:
+		return nil
+	}
+}
+
+// Wait блокується до завершення операції або скасування ctx і повертає Err або помилку контексту.
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resolve завершує Future. Повторні виклики ігноруються.
+func (f *Future) resolve(published bool, err error) {
+	f.once.Do(func() {
+		f.published, f.err = published, err
+		close(f.done)
+	})
+}
+
+// tracked операція, виконання якої відстежується через Future.
+type tracked struct {
+	op     Operation
+	future *Future
+}
+
+func (t *tracked) Do(tx screen.Texture) bool {
+	return t.op.Do(tx)
+}
+
+// Submit додає операцію у чергу з пріоритетом prio і повертає Future, яке завершується після її виконання, у тому
+// числі всіх частин, відкладених через Scheduled. Якщо операція сигналізує про готовність текстури, Future
+// завершується лише після відправлення кадру у Receiver, тож Published повідомляє, чи кадр вже відображено. Якщо
+// операція завершилась панікою, Future завершується з *PanicError.
+func (l *Loop) Submit(ctx context.Context, op Operation, prio Priority) (*Future, error) {
+	f := newFuture()
+	if op == nil {
+		f.resolve(false, nil)
+		return f, nil
+	}
+	f.parts = 1
+	if err := l.MsgQueue.push(ctx, &tracked{op: op, future: f}, prio); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// dropped завершує Future операції op з помилкою err, якщо її виконання відстежується.
+func dropped(op Operation, err error) {
+	if t, ok := op.(*tracked); ok {
+		t.future.resolve(false, err)
+	}
+}
+
+// settle враховує виконання однієї частини відстежуваної операції з Future f. Коли виконано всі частини, Future
+// завершується одразу або, якщо кадр ще не відправлено, разом з його відправленням.
+func (l *Loop) settle(f *Future, ready bool) {
+	if l.lastErr != nil {
+		f.resolve(false, l.lastErr)
+	}
+	f.ready = f.ready || ready
+	if f.parts--; f.parts > 0 {
+		return
+	}
+	if ready || (f.ready && l.pending) {
+		l.waiting = append(l.waiting, f)
+	} else {
+		f.resolve(f.ready, nil)
+	}
+}
@@ -0,0 +1,87 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/draw"
+)
+
+// infiniteBounds межі зображень, які визначені у кожній точці площини.
+var infiniteBounds = image.Rect(-1e9, -1e9, 1e9, 1e9)
+
+// LinearGradient зображення з плавним переходом від кольору From у точці (X1, Y1) до кольору To у точці (X2, Y2).
+// За межами відрізка колір не змінюється.
+type LinearGradient struct {
+	X1, Y1, X2, Y2 int
+	From, To       color.Color
+}
+
+func (g *LinearGradient) ColorModel() color.Model { return color.RGBA64Model }
+
+func (g *LinearGradient) Bounds() image.Rectangle { return infiniteBounds }
+
+func (g *LinearGradient) At(x, y int) color.Color {
+	dx, dy := float64(g.X2-g.X1), float64(g.Y2-g.Y1)
+	l := dx*dx + dy*dy
+	if l == 0 {
+		return lerpColor(g.From, g.To, 0)
+	}
+	px, py := float64(x-g.X1)+0.5, float64(y-g.Y1)+0.5
+	return lerpColor(g.From, g.To, (px*dx+py*dy)/l)
+}
+
+// RadialGradient зображення з плавним переходом від кольору From у центрі (X, Y) до кольору To на відстані R.
+type RadialGradient struct {
+	X, Y, R  int
+	From, To color.Color
+}
+
+func (g *RadialGradient) ColorModel() color.Model { return color.RGBA64Model }
+
+func (g *RadialGradient) Bounds() image.Rectangle { return infiniteBounds }
+
+func (g *RadialGradient) At(x, y int) color.Color {
+	if g.R <= 0 {
+		return lerpColor(g.From, g.To, 1)
+	}
+	d := math.Hypot(float64(x-g.X)+0.5, float64(y-g.Y)+0.5)
+	return lerpColor(g.From, g.To, d/float64(g.R))
+}
+
+// lerpColor змішує кольори a та b у пропорції k, обмеженій діапазоном [0, 1].
+func lerpColor(a, b color.Color, k float64) color.Color {
+	k = math.Max(0, math.Min(1, k))
+	if a == nil {
+		a = color.Black
+	}
+	if b == nil {
+		b = color.Black
+	}
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+	mix := func(u, v uint32) uint16 {
+		return uint16(math.Round(float64(u) + (float64(v)-float64(u))*k))
+	}
+	return color.RGBA64{R: mix(ar, br), G: mix(ag, bg), B: mix(ab, bb), A: mix(aa, ba)}
+}
+
+// RenderPattern малює зображення p, наприклад градієнт, на полотні розміром size. Операції накладають готові пікселі
+// замість того, щоб обчислювати колір кожного пікселя під час кожного малювання.
+func RenderPattern(p image.Image, size image.Point) *image.RGBA {
+	img := image.NewRGBA(image.Rectangle{Max: size})
+	draw.Draw(img, img.Bounds(), p, image.Point{}, draw.Src)
+	return img
+}
+
+// PatternFill зафарбовує всю текстуру зображенням P, наприклад градієнтом, намальованим через RenderPattern.
+type PatternFill struct {
+	P image.Image
+}
+
+func (op *PatternFill) Do(t screen.Texture) bool {
+	drawPattern(t, t.Bounds(), op.P, draw.Src)
+	return false
+}
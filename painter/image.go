@@ -0,0 +1,34 @@
+package painter
+
+import (
+	"image"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Image малює зображення Img з лівим верхнім кутом у (X, Y). Якщо W та H задані, зображення масштабується до цього
+// розміру, інакше малюється у власному розмірі. Name зберігає ім'я ресурсу, з якого взято зображення.
+type Image struct {
+	Name string
+	X, Y int
+	W, H int
+	Img  image.Image
+	// Opacity непрозорість зображення від 0 до 1. Нульове значення означає повну непрозорість.
+	Opacity float64
+}
+
+func (op *Image) Do(t screen.Texture) bool {
+	if op.Img == nil {
+		return false
+	}
+	size := op.Img.Bounds().Size()
+	if op.W > 0 && op.H > 0 {
+		size = image.Pt(op.W, op.H)
+	}
+	opacity := op.Opacity
+	if opacity <= 0 || opacity > 1 {
+		opacity = 1
+	}
+	drawImage(t, image.Rectangle{Min: image.Pt(op.X, op.Y), Max: image.Pt(op.X, op.Y).Add(size)}, op.Img, opacity)
+	return false
+}
@@ -0,0 +1,108 @@
+package lang
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // реєструє декодер JPEG для image.Decode
+	_ "image/png"  // реєструє декодер PNG для image.Decode
+	"io"
+	"log"
+	"net/http"
+	"sync"
+)
+
+const (
+	// maxAssetSize максимальний розмір файлу ресурсу, який приймає AssetHandler.
+	maxAssetSize = 10 << 20
+	// maxAssetPixels максимальна кількість пікселів декодованого зображення.
+	maxAssetPixels = 4096 * 4096
+	// maxAssets максимальна кількість зображень в AssetStore.
+	maxAssets = 64
+)
+
+var (
+	// errAssetTooLarge повертається, якщо зображення містить забагато пікселів.
+	errAssetTooLarge = errors.New("image is too large")
+	// errAssetStoreFull повертається, якщо в AssetStore немає місця для нового зображення.
+	errAssetStoreFull = errors.New("asset store is full")
+)
+
+// AssetStore зберігає декодовані зображення, на які посилається команда image. Методи AssetStore можна викликати
+// з різних горутин одночасно.
+type AssetStore struct {
+	mu     sync.RWMutex
+	images map[string]image.Image
+}
+
+// Put декодує зображення у форматі PNG або JPEG з r і зберігає його під іменем name, замінюючи попереднє.
+// Зображення більше за maxAssetPixels пікселів відхиляються ще до декодування, а нові імена — якщо в сховищі вже
+// maxAssets зображень.
+func (s *AssetStore) Put(name string, r io.Reader) error {
+	if !sceneName.MatchString(name) {
+		return fmt.Errorf("invalid asset name: %s", name)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("invalid image %s: %w", name, err)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid image %s: %w", name, err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxAssetPixels {
+		return fmt.Errorf("%w: %s is %dx%d", errAssetTooLarge, name, cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid image %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.images == nil {
+		s.images = make(map[string]image.Image)
+	}
+	if _, ok := s.images[name]; !ok && len(s.images) >= maxAssets {
+		return fmt.Errorf("%w: %s", errAssetStoreFull, name)
+	}
+	s.images[name] = img
+	return nil
+}
+
+// Get повертає зображення з іменем name.
+func (s *AssetStore) Get(name string) (image.Image, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	img, ok := s.images[name]
+	return img, ok
+}
+
+// AssetHandler конструює обробник HTTP запитів, який приймає PUT або POST запити з PNG або JPEG зображенням у тілі
+// і зберігає його у store під іменем з шаблону маршруту {name}, наприклад "/assets/{name}".
+func AssetHandler(store *AssetStore) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut && r.Method != http.MethodPost {
+			rw.Header().Set("Allow", "PUT, POST")
+			writeError(rw, r, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+
+		name := r.PathValue("name")
+		if err := store.Put(name, http.MaxBytesReader(rw, r.Body, maxAssetSize)); err != nil {
+			log.Printf("Failed to store asset: %s", err)
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			switch {
+			case errors.As(err, &tooLarge), errors.Is(err, errAssetTooLarge):
+				status = http.StatusRequestEntityTooLarge
+			case errors.Is(err, errAssetStoreFull):
+				status = http.StatusInsufficientStorage
+			}
+			writeError(rw, r, status, err)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	})
+}
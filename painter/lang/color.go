@@ -0,0 +1,87 @@
+package lang
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// parseColor розбирає колір у одному з форматів: #rgb, #rgba, #rrggbb, #rrggbbaa, rgb(r,g,b), rgba(r,g,b,a)
+// або назва кольору CSS (red, navy, transparent тощо).
+func parseColor(s string) (color.Color, error) {
+	lower := strings.ToLower(s)
+	switch {
+	case strings.HasPrefix(lower, "#"):
+		return parseHexColor(lower[1:])
+	case strings.HasPrefix(lower, "rgb(") || strings.HasPrefix(lower, "rgba("):
+		return parseFuncColor(lower)
+	case lower == "transparent":
+		return color.NRGBA{}, nil
+	}
+	if c, ok := colornames.Map[lower]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("invalid color: %s", s)
+}
+
+// parseHexColor розбирає шістнадцятковий запис кольору без символу #.
+func parseHexColor(hex string) (color.Color, error) {
+	switch len(hex) {
+	case 3, 4:
+		// Короткий запис: кожна цифра повторюється двічі.
+		var long strings.Builder
+		for _, r := range hex {
+			long.WriteRune(r)
+			long.WriteRune(r)
+		}
+		hex = long.String()
+	case 6, 8:
+	default:
+		return nil, fmt.Errorf("invalid color: #%s", hex)
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color: #%s", hex)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// parseFuncColor розбирає запис кольору rgb(r,g,b) або rgba(r,g,b,a), де компоненти задаються в діапазоні 0-255,
+// а прозорість — в діапазоні 0-1.
+func parseFuncColor(s string) (color.Color, error) {
+	open := strings.IndexByte(s, '(')
+	if !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("invalid color: %s", s)
+	}
+	name, body := s[:open], s[open+1:len(s)-1]
+	parts := strings.Split(body, ",")
+	if (name == "rgb" && len(parts) != 3) || (name == "rgba" && len(parts) != 4) {
+		return nil, fmt.Errorf("invalid color: %s", s)
+	}
+
+	var c [4]uint8
+	c[3] = 0xff
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if i == 3 {
+			a, err := strconv.ParseFloat(part, 64)
+			if err != nil || a < 0 || a > 1 {
+				return nil, fmt.Errorf("invalid color: %s", s)
+			}
+			c[i] = uint8(a*0xff + 0.5)
+			continue
+		}
+		v, err := strconv.ParseUint(part, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid color: %s", s)
+		}
+		c[i] = uint8(v)
+	}
+	return color.NRGBA{R: c[0], G: c[1], B: c[2], A: c[3]}, nil
+}
@@ -0,0 +1,88 @@
+package lang
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseError описує помилку у скрипті разом з позицією, на якій вона виникла.
+type ParseError struct {
+	Line     int    `json:"line"`               // номер рядка скрипта, починаючи з 1
+	Column   int    `json:"column"`             // номер символу у рядку, з якого починається Token, починаючи з 1
+	Token    string `json:"token,omitempty"`    // аргумент, який не вдалося розібрати
+	Expected string `json:"expected,omitempty"` // очікувана форма команди
+	Message  string `json:"message"`
+
+	err error // початкова помилка, якщо є
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+	if e.Expected != "" {
+		msg += "; expected: " + e.Expected
+	}
+	return msg
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.err
+}
+
+// token аргумент рядка команди разом з номером символу, з якого він починається.
+type token struct {
+	text string
+	col  int
+}
+
+// errorAt створює помилку, яка вказує на аргумент tok. Номер рядка та очікувану форму команди заповнює Parse.
+func errorAt(tok token, format string, args ...any) *ParseError {
+	return &ParseError{Column: tok.col, Token: tok.text, Message: fmt.Sprintf(format, args...)}
+}
+
+// wrapAt перетворює помилку err на ParseError, яка вказує на аргумент tok.
+func wrapAt(tok token, err error) *ParseError {
+	return &ParseError{Column: tok.col, Token: tok.text, Message: err.Error(), err: err}
+}
+
+// usage описує очікувану форму кожної команди.
+var usage = map[string]string{
+	"white":    "white",
+	"green":    "green",
+	"fill":     "fill <color>",
+	"gradient": "gradient linear x1 y1 x2 y2 <color> <color> | gradient radial x y r <color> <color>",
+	"update":   "update",
+	"undo":     "undo",
+	"redo":     "redo",
+	"reset":    "reset",
+	"bgrect":   "bgrect x1 y1 x2 y2 [color|linear(...)|radial(...)] [opacity=N]",
+	"figure":   "figure [id] x y [size] [color] [opacity=N]",
+	"move":     "move [id] dx dy",
+	"animate":  "animate <id> dx dy <ms> [linear|ease-in|ease-out|ease-in-out|bounce]",
+	"delete":   "delete <id>",
+	"color":    "color <id> <color>",
+	"circle":   "circle x y r [fill|outline] [color] [width=N] [opacity=N]",
+	"ellipse":  "ellipse x y rx ry [fill|outline] [color] [width=N] [opacity=N]",
+	"line":     "line x1 y1 x2 y2 [color] [width=N] [opacity=N]",
+	"polyline": "polyline x1 y1 x2 y2 ... [color] [width=N] [opacity=N]",
+	"polygon":  "polygon x1 y1 x2 y2 x3 y3 ... [color] [opacity=N]",
+	"text":     "text x y size \"string\" [color] [opacity=N]",
+	"image":    "image <asset> x y [w h] [opacity=N]",
+	"save":     "save <name>",
+	"load":     "load <name>",
+	"wait":     "wait <ms>",
+	"at":       "at <ms>",
+}
+
+// expectedForm повертає очікувану форму команди instruction або перелік відомих команд.
+func expectedForm(instruction string) string {
+	if form, ok := usage[instruction]; ok {
+		return form
+	}
+	names := make([]string, 0, len(usage))
+	for name := range usage {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "one of " + strings.Join(names, ", ")
+}
@@ -0,0 +1,126 @@
+package lang
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/sifes/kpi-3-lab3/painter"
+)
+
+// gradient описує градієнт у нормалізованих координатах. Для лінійного градієнта coords містить x1 y1 x2 y2,
+// для радіального — x y r, де радіус нормалізується за меншою стороною полотна.
+type gradient struct {
+	kind     string
+	coords   []float64
+	from, to color.Color
+}
+
+// gradientCoords кількість координат кожного виду градієнта.
+var gradientCoords = map[string]int{"linear": 4, "radial": 3}
+
+// parseGradient розбирає градієнт у записі linear(x1, y1, x2, y2, c1, c2) або radial(x, y, r, c1, c2).
+func parseGradient(s string) (*gradient, error) {
+	open := strings.IndexByte(s, '(')
+	if open < 0 || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("invalid gradient: %s", s)
+	}
+	kind := strings.ToLower(s[:open])
+	n, ok := gradientCoords[kind]
+	if !ok {
+		return nil, fmt.Errorf("invalid gradient: %s", s)
+	}
+	parts := splitArgs(s[open+1 : len(s)-1])
+	if len(parts) != n+2 {
+		return nil, fmt.Errorf("%s gradient requires %d coordinates and 2 colors: %s", kind, n, s)
+	}
+	coords := make([]float64, n)
+	for i, part := range parts[:n] {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("invalid number %s", part)
+		}
+		coords[i] = v
+	}
+	from, err := parseColor(parts[n])
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseColor(parts[n+1])
+	if err != nil {
+		return nil, err
+	}
+	return newGradient(kind, coords, from, to)
+}
+
+// newGradient створює градієнт виду kind з нормалізованими координатами coords.
+func newGradient(kind string, coords []float64, from, to color.Color) (*gradient, error) {
+	if kind == "radial" && coords[2] < 0 {
+		return nil, fmt.Errorf("radius must not be negative, got %g", coords[2])
+	}
+	return &gradient{kind: kind, coords: coords, from: from, to: to}, nil
+}
+
+// String записує градієнт у тому ж вигляді, який приймає parseGradient.
+func (g *gradient) String() string {
+	parts := make([]string, 0, len(g.coords)+2)
+	for _, v := range g.coords {
+		parts = append(parts, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	parts = append(parts, formatColor(g.from), formatColor(g.to))
+	return g.kind + "(" + strings.Join(parts, ",") + ")"
+}
+
+// withOpacity повертає градієнт, кольори якого враховують непрозорість opacity.
+func (g *gradient) withOpacity(opacity float64) *gradient {
+	st := style{opacity: opacity}
+	return &gradient{kind: g.kind, coords: g.coords, from: st.colorOr(g.from), to: st.colorOr(g.to)}
+}
+
+// image малює градієнт на полотні розміром size. Функція conv перетворює нормалізовані координати у пікселі.
+func (g *gradient) image(size image.Point, conv func(float64, int) int) *image.RGBA {
+	var img image.Image
+	v := g.coords
+	if g.kind == "radial" {
+		img = &painter.RadialGradient{
+			X: conv(v[0], size.X), Y: conv(v[1], size.Y), R: conv(v[2], min(size.X, size.Y)),
+			From: g.from, To: g.to,
+		}
+	} else {
+		img = &painter.LinearGradient{
+			X1: conv(v[0], size.X), Y1: conv(v[1], size.Y), X2: conv(v[2], size.X), Y2: conv(v[3], size.Y),
+			From: g.from, To: g.to,
+		}
+	}
+	return painter.RenderPattern(img, size)
+}
+
+// isGradient перевіряє, чи записаний аргумент s як градієнт.
+func isGradient(s string) bool {
+	lower := strings.ToLower(s)
+	return strings.HasPrefix(lower, "linear(") || strings.HasPrefix(lower, "radial(")
+}
+
+// splitArgs розбиває перелік аргументів через кому, не розриваючи вирази в дужках, наприклад "rgb(1, 2, 3)".
+func splitArgs(s string) []string {
+	var (
+		parts []string
+		depth int
+		start int
+	)
+	for i, r := range s {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
@@ -1,18 +1,43 @@
 package lang
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/sifes/kpi-3-lab3/painter"
 )
 
 // HttpHandler конструює обробник HTTP запитів, який дані з запиту віддає у Parser, а потім відправляє отриманий список
-// операцій у painter.Loop.
+// операцій у painter.Loop. Параметр запиту priority (low, normal або high) задає смугу черги, наприклад щоб reset
+// випередив накопичені операції малювання. З параметром wait=true відповідь надсилається лише після виконання
+// операцій, у тому числі відкладених командами wait, at та animate, а заголовок X-Frame-Published повідомляє, чи було
+// відображено новий кадр.
 func HttpHandler(loop *painter.Loop, p *Parser) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		prio := painter.PriorityNormal
+		if v := r.URL.Query().Get("priority"); v != "" {
+			if err := prio.UnmarshalText([]byte(v)); err != nil {
+				writeError(rw, r, http.StatusBadRequest, err)
+				return
+			}
+		}
+		var wait bool
+		if v := r.URL.Query().Get("wait"); v != "" {
+			var err error
+			if wait, err = strconv.ParseBool(v); err != nil {
+				writeError(rw, r, http.StatusBadRequest, fmt.Errorf("invalid wait parameter: %s", v))
+				return
+			}
+		}
+
 		var in io.Reader = r.Body
 		if r.Method == http.MethodGet {
 			in = strings.NewReader(r.URL.Query().Get("cmd"))
@@ -21,11 +46,139 @@ func HttpHandler(loop *painter.Loop, p *Parser) http.Handler {
 		cmds, err := p.Parse(in)
 		if err != nil {
 			log.Printf("Bad script: %s", err)
-			rw.WriteHeader(http.StatusBadRequest)
+			writeError(rw, r, http.StatusBadRequest, err)
+			return
+		}
+
+		if !wait {
+			if err := loop.PostPriority(r.Context(), painter.OperationList(cmds), prio); err != nil {
+				log.Printf("Failed to post operations: %s", err)
+				writePostError(rw, r, err)
+				return
+			}
+			rw.WriteHeader(http.StatusOK)
 			return
 		}
 
-		loop.Post(painter.OperationList(cmds))
+		f, err := loop.Submit(r.Context(), painter.OperationList(cmds), prio)
+		if err == nil {
+			err = f.Wait(r.Context())
+		}
+		if err != nil {
+			writeWaitError(rw, r, err)
+			return
+		}
+		rw.Header().Set("X-Frame-Published", strconv.FormatBool(f.Published()))
+		rw.WriteHeader(http.StatusOK)
+	})
+}
+
+// CommandHandler конструює обробник HTTP запитів, який на кожен POST запит виконує фіксований скрипт script,
+// наприклад "undo" або "redo", і відправляє отриманий список операцій у painter.Loop.
+func CommandHandler(loop *painter.Loop, p *Parser, script string) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			rw.Header().Set("Allow", http.MethodPost)
+			writeError(rw, r, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+
+		cmds, err := p.Parse(strings.NewReader(script))
+		if err != nil {
+			writeError(rw, r, http.StatusConflict, err)
+			return
+		}
+
+		if err := loop.PostContext(r.Context(), painter.OperationList(cmds)); err != nil {
+			log.Printf("Failed to post operations: %s", err)
+			writePostError(rw, r, err)
+			return
+		}
 		rw.WriteHeader(http.StatusOK)
 	})
 }
+
+// SnapshotHandler конструює обробник HTTP запитів, який повертає кадр, відправлений у Receiver останнім, у форматі PNG.
+func SnapshotHandler(loop *painter.Loop) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		img := loop.Snapshot()
+		if img == nil {
+			http.Error(rw, "no frame has been rendered yet", http.StatusNotFound)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			log.Printf("Failed to encode snapshot: %s", err)
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "image/png")
+		rw.Header().Set("Cache-Control", "no-store")
+		_, _ = rw.Write(buf.Bytes())
+	})
+}
+
+// writePostError відправляє клієнту помилку, отриману від painter.Loop під час додавання операцій у чергу.
+// Заповнена черга або відкинуті через її переповнення операції повідомляються статусом 429, а зупинений цикл —
+// статусом 503.
+func writePostError(rw http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusServiceUnavailable
+	if errors.Is(err, painter.ErrQueueFull) || errors.Is(err, painter.ErrOperationDropped) {
+		status = http.StatusTooManyRequests
+		rw.Header().Set("Retry-After", "1")
+	}
+	writeError(rw, r, status, err)
+}
+
+// writeWaitError відправляє клієнту помилку, з якою не вдалося дочекатися виконання операцій. Паніка операції
+// повідомляється статусом 500, а якщо клієнт сам скасував запит, відповідь не надсилається.
+func writeWaitError(rw http.ResponseWriter, r *http.Request, err error) {
+	var pe *painter.PanicError
+	switch {
+	case errors.As(err, &pe):
+		log.Printf("Failed to execute operations: %s", err)
+		writeError(rw, r, http.StatusInternalServerError, err)
+	case r.Context().Err() != nil && errors.Is(err, r.Context().Err()):
+		log.Printf("Client closed request before operations were executed: %s", err)
+	default:
+		log.Printf("Failed to execute operations: %s", err)
+		writePostError(rw, r, err)
+	}
+}
+
+// errorBody описує помилку у відповіді у форматі JSON.
+type errorBody struct {
+	Error string      `json:"error"`
+	Parse *ParseError `json:"parse,omitempty"`
+}
+
+// writeError відправляє клієнту помилку у форматі JSON, якщо клієнт його приймає, або у вигляді тексту.
+func writeError(rw http.ResponseWriter, r *http.Request, status int, err error) {
+	if !acceptsJSON(r) {
+		http.Error(rw, err.Error(), status)
+		return
+	}
+
+	body := errorBody{Error: err.Error()}
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		body.Parse = pe
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(status)
+	_ = json.NewEncoder(rw).Encode(body)
+}
+
+// acceptsJSON перевіряє, чи вказав клієнт JSON серед прийнятних форматів відповіді.
+func acceptsJSON(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if mediaType == "application/json" {
+			return true
+		}
+	}
+	return false
+}
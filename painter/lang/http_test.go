@@ -0,0 +1,187 @@
+package lang
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sifes/kpi-3-lab3/painter"
+	"github.com/sifes/kpi-3-lab3/ui/headless"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpHandler_ParseError(t *testing.T) {
+	var (
+		loop   painter.Loop
+		parser Parser
+	)
+	h := HttpHandler(&loop, &parser)
+	script := "white\nfigure 0.5 abc"
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(script))
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "line 2, column 12: invalid number abc; expected: figure [id] x y [size] [color] [opacity=N]\n", rw.Body.String())
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(script))
+	req.Header.Set("Accept", "text/html, application/json;q=0.9")
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+
+	var body errorBody
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+	assert.Equal(t, &ParseError{
+		Line:     2,
+		Column:   12,
+		Token:    "abc",
+		Expected: "figure [id] x y [size] [color] [opacity=N]",
+		Message:  "invalid number abc",
+	}, body.Parse)
+}
+
+func TestHttpHandler_QueueFull(t *testing.T) {
+	var (
+		loop   painter.Loop
+		parser Parser
+	)
+	loop.MsgQueue.Capacity = 1
+	loop.MsgQueue.Policy = painter.OverflowReject
+	h := HttpHandler(&loop, &parser)
+
+	// Цикл не запущено, тож перша операція залишається у черзі.
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("white")))
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("green")))
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "1", rw.Header().Get("Retry-After"))
+}
+
+func TestHttpHandler_Wait(t *testing.T) {
+	var (
+		loop   painter.Loop
+		parser Parser
+		rec    headless.Recorder
+	)
+	loop.Receiver = &rec
+	loop.Start(headless.Screen{})
+	defer loop.StopAndWait()
+	h := HttpHandler(&loop, &parser)
+
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/?wait=true", strings.NewReader("green\nupdate")))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "true", rw.Header().Get("X-Frame-Published"))
+	assert.NotNil(t, rec.Frame())
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/?wait=true", strings.NewReader("white")))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "false", rw.Header().Get("X-Frame-Published"))
+
+	// Відповідь надсилається лише після виконання відрізків, відкладених командою wait.
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/?wait=true", strings.NewReader("white\nupdate\nwait 50\ngreen\nupdate")))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "true", rw.Header().Get("X-Frame-Published"))
+	assert.Equal(t, color.RGBA{G: 0xff, A: 0xff}, rec.Frame().RGBAAt(0, 0))
+
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/?wait=maybe", strings.NewReader("white")))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	// Клієнт, який скасував запит, відповіді не отримує.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rw = httptest.NewRecorder()
+	h.ServeHTTP(rw, httptest.NewRequest(http.MethodPost, "/?wait=true", strings.NewReader("white\nwait 50\nupdate")).WithContext(ctx))
+	assert.Zero(t, rw.Body.Len())
+	assert.Empty(t, rw.Header())
+
+	// Паніка операції є помилкою сервера, а не недоступністю сервісу.
+	rw = httptest.NewRecorder()
+	writeWaitError(rw, httptest.NewRequest(http.MethodPost, "/?wait=true", nil), &painter.PanicError{Value: "boom"})
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+}
+
+func TestAssetHandler(t *testing.T) {
+	var (
+		assets AssetStore
+		buf    bytes.Buffer
+	)
+	mux := http.NewServeMux()
+	mux.Handle("/assets/{name}", AssetHandler(&assets))
+
+	src := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	assert.NoError(t, png.Encode(&buf, src))
+
+	rw := httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/assets/logo", &buf))
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	img, ok := assets.Get("logo")
+	assert.True(t, ok)
+	assert.Equal(t, src.Bounds(), img.Bounds())
+
+	rw = httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/assets/broken", strings.NewReader("not an image")))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/assets/logo", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rw.Code)
+
+	// Розмір зображення перевіряється за заголовком, до декодування пікселів.
+	rw = httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/assets/huge", bytes.NewReader(pngHeader(1<<16, 1<<16))))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rw.Code)
+
+	var small bytes.Buffer
+	assert.NoError(t, png.Encode(&small, image.NewRGBA(image.Rect(0, 0, 1, 1))))
+	for i := 1; i < maxAssets; i++ {
+		assert.NoError(t, assets.Put(fmt.Sprintf("a%d", i), bytes.NewReader(small.Bytes())))
+	}
+	rw = httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest(http.MethodPut, "/assets/extra", bytes.NewReader(small.Bytes())))
+	assert.Equal(t, http.StatusInsufficientStorage, rw.Code)
+	assert.NoError(t, assets.Put("a1", bytes.NewReader(small.Bytes())))
+
+	parser := &Parser{Assets: &assets}
+	ops, err := parser.Parse(strings.NewReader("image logo 0.5 0.25\nimage logo 0 0 0.1 0.05"))
+	assert.NoError(t, err)
+	assert.Equal(t, &painter.Image{Name: "logo", X: 400, Y: 200, Img: img}, ops[1])
+	assert.Equal(t, &painter.Image{Name: "logo", W: 80, H: 40, Img: img}, ops[2])
+
+	restored := &Parser{Assets: &assets}
+	assert.NoError(t, restored.SetScene(parser.Scene()))
+	assert.Equal(t, parser.Scene(), restored.Scene())
+
+	for _, script := range []string{"image missing 0 0", "image logo 0 0 0.1", "image logo 0 0 0 0.1"} {
+		_, err = parser.Parse(strings.NewReader(script))
+		assert.Error(t, err, script)
+	}
+}
+
+// pngHeader повертає початок PNG файлу з заголовком зображення розміром w x h без пікселів.
+func pngHeader(w, h uint32) []byte {
+	ihdr := binary.BigEndian.AppendUint32([]byte("IHDR"), w)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, h)
+	ihdr = append(ihdr, 8, 6, 0, 0, 0)
+	data := append([]byte("\x89PNG\r\n\x1a\n"), 0, 0, 0, 13)
+	data = append(data, ihdr...)
+	return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(ihdr))
+}
@@ -2,29 +2,72 @@ package lang
 
 import (
 	"bufio"
-	"fmt"
+	"errors"
+	"image"
 	"image/color"
 	"io"
+	"math"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sifes/kpi-3-lab3/painter"
 )
 
 // Parser уміє прочитати дані з вхідного io.Reader та повернути список операцій представлені вхідним скриптом.
+// Методи Parser можна викликати з різних горутин одночасно.
 type Parser struct {
+	mu sync.Mutex
+
+	// SceneDir каталог, у якому команди save та load зберігають і шукають сцени.
+	SceneDir string
+	// HistoryLimit максимальна кількість станів, які зберігаються для команди undo. Нульове значення
+	// означає defaultHistoryLimit.
+	HistoryLimit int
+	// CanvasSize розмір полотна, на яке відображаються нормалізовані координати: 0 відповідає лівому або
+	// верхньому краю, 1 — правому або нижньому. Нульове значення означає painter.DefaultCanvasSize.
+	CanvasSize image.Point
+	// Assets зображення, доступні команді image.
+	Assets *AssetStore
+
+	background  string // опис фону у форматі Scene.Background
 	lastBgColor painter.Operation
 	lastBgRect  *painter.BgRectangle
-	figures     []*painter.Figure
+	rectFill    *gradient         // градієнт прямокутника lastBgRect, з якого намальовано lastBgRect.Fill
+	figures     []*painter.Figure // фігури парсера, у цикл подій передаються лише їх копії
+	shapes      []painter.Operation
 	moveOps     []painter.Operation
 	updateOp    painter.Operation
+
+	// stage поточна сцена циклу подій, яку перемальовують кадри анімацій. Створюється першою командою animate.
+	stage *painter.Stage
+
+	history []*Scene // стани сцени на момент виконання команд update, останній — поточний
+	undone  []*Scene // стани, скасовані командою undo, які можна повернути командою redo
+
+	// Стан розбору одного скрипта, розбитого командами wait та at на відрізки.
+	start  time.Time           // момент початку розбору, від якого відраховуються затримки
+	offset time.Duration       // затримка поточного відрізка
+	out    []painter.Operation // операції попередніх відрізків
+	dirty  bool                // у поточному відрізку були команди
+	begins []painter.Operation // операції початку анімацій поточного відрізка
+	frames []painter.Operation // кадри анімацій поточного відрізка
 }
 
+// defaultHistoryLimit кількість станів, які зберігаються для команди undo за замовчуванням.
+const defaultHistoryLimit = 100
+
+// maxAnimationDuration максимальна тривалість анімації, яку приймає команда animate.
+const maxAnimationDuration = 5 * time.Minute
+
 // initialize встановлює початковий стан парсера, якщо необхідно
 func (p *Parser) initialize() {
 	if p.lastBgColor == nil && p.lastBgRect == nil &&
-		len(p.figures) == 0 && len(p.moveOps) == 0 && p.updateOp == nil {
+		len(p.figures) == 0 && len(p.shapes) == 0 && len(p.moveOps) == 0 && p.updateOp == nil {
 		p.lastBgColor = painter.OperationFunc(painter.ResetScreen)
+		p.background = "reset"
 	}
 
 	if p.updateOp != nil {
@@ -34,136 +77,891 @@ func (p *Parser) initialize() {
 
 // Parse читає команди з io.Reader і повертає список операцій
 func (p *Parser) Parse(in io.Reader) ([]painter.Operation, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	p.initialize()
+	if len(p.history) == 0 {
+		// Початковий стан дозволяє скасувати навіть перше update.
+		p.history = []*Scene{p.scene()}
+	}
+	p.start, p.offset, p.out, p.dirty = time.Now(), 0, nil, false
+	p.begins, p.frames = nil, nil
+	saved := p.save()
 	scanner := bufio.NewScanner(in)
 	scanner.Split(bufio.ScanLines)
 
-	for scanner.Scan() {
+	for line := 1; scanner.Scan(); line++ {
 		commandLine := scanner.Text()
 		if len(strings.TrimSpace(commandLine)) > 0 {
 			err := p.parse(commandLine)
 			if err != nil {
+				err.Line = line
+				err.Expected = expectedForm(splitFields(commandLine)[0].text)
+				p.restore(saved)
 				return nil, err
 			}
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		p.restore(saved)
+		return nil, err
+	}
+
+	p.flush()
+	res := p.out
+	p.out = nil
+	return res, nil
+}
+
+// parserState копія стану парсера, з якої відновлюється стан, якщо скрипт не вдалося розібрати.
+type parserState struct {
+	background      string
+	lastBgColor     painter.Operation
+	lastBgRect      *painter.BgRectangle
+	rectFill        *gradient
+	figures         []*painter.Figure
+	positions       []painter.Figure // значення фігур figures, які скрипт міг змінити
+	shapes, moveOps []painter.Operation
+	history, undone []*Scene
+}
+
+// save запам'ятовує стан парсера перед розбором скрипта.
+func (p *Parser) save() *parserState {
+	s := &parserState{
+		background:  p.background,
+		lastBgColor: p.lastBgColor,
+		lastBgRect:  p.lastBgRect,
+		rectFill:    p.rectFill,
+		figures:     append([]*painter.Figure(nil), p.figures...),
+		positions:   make([]painter.Figure, len(p.figures)),
+		shapes:      append([]painter.Operation(nil), p.shapes...),
+		moveOps:     append([]painter.Operation(nil), p.moveOps...),
+		history:     append([]*Scene(nil), p.history...),
+		undone:      append([]*Scene(nil), p.undone...),
+	}
+	for i, fig := range p.figures {
+		s.positions[i] = *fig
+	}
+	return s
+}
 
-	return p.finalResult(), nil
+// restore відновлює стан парсера, збережений через save, тож скрипт з помилкою не змінює ні сцену, ні історію.
+func (p *Parser) restore(s *parserState) {
+	p.background, p.lastBgColor, p.lastBgRect, p.rectFill = s.background, s.lastBgColor, s.lastBgRect, s.rectFill
+	p.figures, p.shapes, p.moveOps = s.figures, s.shapes, s.moveOps
+	for i, fig := range s.figures {
+		*fig = s.positions[i]
+	}
+	p.history, p.undone = s.history, s.undone
+	p.updateOp, p.out, p.begins, p.frames = nil, nil, nil, nil
 }
 
-// finalResult збирає всі операції в один список
+// flush завершує поточний відрізок скрипта: додає стан сцени до результату, обгортаючи його у painter.Scheduled,
+// якщо відрізок має затримку.
+func (p *Parser) flush() {
+	if !p.dirty && len(p.out) > 0 {
+		return
+	}
+	ops := p.finalResult()
+	if p.offset > 0 {
+		p.out = append(p.out, &painter.Scheduled{At: p.start.Add(p.offset), Op: painter.OperationList(ops)})
+	} else {
+		p.out = append(p.out, ops...)
+	}
+	// Кадри анімацій додаються після відрізка, щоб навіть миттєва анімація виконалась після її початку.
+	p.out = append(p.out, p.frames...)
+	p.frames = nil
+	p.updateOp = nil
+	p.dirty = false
+}
+
+// finalResult збирає всі операції в один список. Фігури передаються у цикл подій копіями: цикл змінює їх під час
+// переміщення, а наступні команди змінюють лише фігури парсера, тож уже відправлені операції не змінюються.
 func (p *Parser) finalResult() []painter.Operation {
-	var res []painter.Operation
+	var res, scene []painter.Operation
 	if p.lastBgColor != nil {
-		res = append(res, p.lastBgColor)
+		scene = append(scene, p.lastBgColor)
 	}
 	if p.lastBgRect != nil {
-		res = append(res, p.lastBgRect)
+		scene = append(scene, p.lastBgRect)
 	}
-	if len(p.moveOps) != 0 {
-		res = append(res, p.moveOps...)
-		p.moveOps = nil
+	scene = append(scene, p.shapes...)
+	copies := p.copyFigures()
+	scene = p.appendFigures(scene, copies)
+
+	// Переміщення та зміщення анімацій мають виконатися до того, як будуть намальовані фігури.
+	n := len(scene) - len(p.figures)
+	res = append(res, scene[:n]...)
+	for _, op := range p.moveOps {
+		res = append(res, p.applyMove(op.(*painter.Move), copies))
 	}
-	for _, figure := range p.figures {
-		res = append(res, figure)
+	p.moveOps = nil
+	res = append(res, p.begins...)
+	p.begins = nil
+	if p.stage != nil {
+		res = append(res, p.stage.Enter(scene...))
 	}
+	res = append(res, scene[n:]...)
 	if p.updateOp != nil {
 		res = append(res, p.updateOp)
 	}
 	return res
 }
 
+// copyFigures копіює фігури сцени для передачі у цикл подій.
+func (p *Parser) copyFigures() map[*painter.Figure]*painter.Figure {
+	copies := make(map[*painter.Figure]*painter.Figure, len(p.figures))
+	for _, fig := range p.figures {
+		c := *fig
+		copies[fig] = &c
+	}
+	return copies
+}
+
+// appendFigures додає копії фігур сцени до списку операцій.
+func (p *Parser) appendFigures(res []painter.Operation, copies map[*painter.Figure]*painter.Figure) []painter.Operation {
+	for _, fig := range p.figures {
+		res = append(res, copies[fig])
+	}
+	return res
+}
+
+// applyMove переміщує фігури парсера і повертає операцію, яка так само переміщує їх копії у циклі подій.
+func (p *Parser) applyMove(move *painter.Move, copies map[*painter.Figure]*painter.Figure) *painter.Move {
+	res := &painter.Move{X: move.X, Y: move.Y}
+	for _, fig := range move.Figures {
+		fig.X += move.X
+		fig.Y += move.Y
+		if c, ok := copies[fig]; ok {
+			res.Figures = append(res.Figures, c)
+		}
+	}
+	return res
+}
+
 // resetState скидає всі стани парсера
 func (p *Parser) resetState() {
+	p.background = ""
 	p.lastBgColor = nil
 	p.lastBgRect = nil
+	p.rectFill = nil
 	p.figures = nil
+	p.shapes = nil
 	p.moveOps = nil
 	p.updateOp = nil
 }
 
 // parse обробляє один рядок команди
-func (p *Parser) parse(commandLine string) error {
-	parts := strings.Fields(commandLine)
+func (p *Parser) parse(commandLine string) *ParseError {
+	parts := splitFields(commandLine)
 	if len(parts) == 0 {
 		return nil
 	}
 
-	instruction := parts[0]
-	var args []string
+	cmd := parts[0]
+	instruction := cmd.text
+	size := p.canvasSize()
+	var args []token
 	if len(parts) > 1 {
 		args = parts[1:]
 	}
 
 	switch instruction {
+	case "wait", "at":
+		if len(args) != 1 {
+			return errorAt(cmd, "%s requires 1 argument, got %d", instruction, len(args))
+		}
+		ms, err := strconv.ParseFloat(args[0].text, 64)
+		if err != nil || ms < 0 {
+			return errorAt(args[0], "invalid delay %s", args[0].text)
+		}
+		d := time.Duration(ms * float64(time.Millisecond))
+		if instruction == "wait" {
+			d += p.offset
+		} else if d < p.offset {
+			return errorAt(args[0], "at %s is earlier than the current offset %s", args[0].text, p.offset)
+		}
+		p.flush()
+		p.offset = d
+		return nil
 	case "white":
 		p.lastBgColor = painter.OperationFunc(painter.WhiteFill)
+		p.background = instruction
 	case "green":
 		p.lastBgColor = painter.OperationFunc(painter.GreenFill)
+		p.background = instruction
+	case "fill":
+		if len(args) != 1 {
+			return errorAt(cmd, "fill requires 1 argument, got %d", len(args))
+		}
+		c, err := parseColor(args[0].text)
+		if err != nil {
+			return wrapAt(args[0], err)
+		}
+		p.lastBgColor = &painter.ColorFill{C: c}
+		p.background = formatColor(c)
+	case "gradient":
+		if len(args) == 0 {
+			return errorAt(cmd, "gradient requires linear or radial kind")
+		}
+		kind := strings.ToLower(args[0].text)
+		n, ok := gradientCoords[kind]
+		if !ok {
+			return errorAt(args[0], "unknown gradient kind %s", args[0].text)
+		}
+		if len(args) != n+3 {
+			return errorAt(cmd, "gradient %s requires %d arguments, got %d", kind, n+2, len(args)-1)
+		}
+		v, err := parseFloats(args[1 : n+1])
+		if err != nil {
+			return err
+		}
+		var c [2]color.Color
+		for i, arg := range args[n+1:] {
+			col, err := parseColor(arg.text)
+			if err != nil {
+				return wrapAt(arg, err)
+			}
+			c[i] = col
+		}
+		g, gerr := newGradient(kind, v, c[0], c[1])
+		if gerr != nil {
+			return wrapAt(args[n], gerr)
+		}
+		p.lastBgColor = &painter.PatternFill{P: g.image(size, scale)}
+		p.background = g.String()
 	case "update":
 		p.updateOp = painter.UpdateOp
+		p.commit()
+	case "undo", "redo":
+		if len(args) != 0 {
+			return errorAt(cmd, "%s takes no arguments, got %d", instruction, len(args))
+		}
+		var err error
+		if instruction == "undo" {
+			err = p.undo()
+		} else {
+			err = p.redo()
+		}
+		if err != nil {
+			return wrapAt(cmd, err)
+		}
+		p.updateOp = painter.UpdateOp
 	case "bgrect":
-		if len(args) != 4 {
-			return fmt.Errorf("bgrect requires 4 arguments, got %d", len(args))
+		if len(args) < 4 || len(args) > 6 {
+			return errorAt(cmd, "bgrect requires 4 to 6 arguments, got %d", len(args))
 		}
-		x1, err1 := strconv.ParseFloat(args[0], 64)
-		y1, err2 := strconv.ParseFloat(args[1], 64)
-		x2, err3 := strconv.ParseFloat(args[2], 64)
-		y2, err4 := strconv.ParseFloat(args[3], 64)
-
-		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
-			return fmt.Errorf("invalid arguments for bgrect")
+		v, err := parseFloats(args[:4])
+		if err != nil {
+			return err
+		}
+		st, err := parseStyle(args[4:], styleOpacity|styleGradient)
+		if err != nil {
+			return err
 		}
 
 		// Конвертуємо нормалізовані координати у пікселі
 		p.lastBgRect = &painter.BgRectangle{
-			X1: int(x1 * 800),
-			Y1: int(y1 * 800),
-			X2: int(x2 * 800),
-			Y2: int(y2 * 800),
+			X1: scale(v[0], size.X),
+			Y1: scale(v[1], size.Y),
+			X2: scale(v[2], size.X),
+			Y2: scale(v[3], size.Y),
+			C:  st.colorOr(color.Black),
+		}
+		p.rectFill = nil
+		if st.gradient != nil {
+			p.rectFill = st.gradient.withOpacity(st.opacity)
+			p.lastBgRect.Fill = p.rectFill.image(size, scale)
 		}
 	case "figure":
-		if len(args) != 2 {
-			return fmt.Errorf("figure requires 2 arguments, got %d", len(args))
+		var id string
+		if len(args) > 0 && !isNumber(args[0].text) {
+			id, args = args[0].text, args[1:]
 		}
-		x, err1 := strconv.ParseFloat(args[0], 64)
-		y, err2 := strconv.ParseFloat(args[1], 64)
-
-		if err1 != nil || err2 != nil {
-			return fmt.Errorf("invalid arguments for figure")
+		n := 2
+		if len(args) > 2 && isNumber(args[2].text) {
+			n = 3 // розмір фігури
+		}
+		if len(args) < 2 || len(args) > n+2 {
+			return errorAt(cmd, "figure requires 2 to 5 arguments, got %d", len(args))
+		}
+		v, err := parseFloats(args[:n])
+		if err != nil {
+			return err
+		}
+		st, err := parseStyle(args[n:], styleOpacity)
+		if err != nil {
+			return err
 		}
 
-		// Конвертуємо нормалізовані координати у пікселі
+		// Конвертуємо нормалізовані координати у пікселі. Розмір задається відносно меншої сторони полотна.
 		fig := &painter.Figure{
-			X: int(x * 800),
-			Y: int(y * 800),
-			C: color.RGBA{B: 255, A: 255},
+			ID: id,
+			X:  scale(v[0], size.X),
+			Y:  scale(v[1], size.Y),
+			C:  color.RGBAModel.Convert(st.colorOr(defaultShapeColor)).(color.RGBA),
+		}
+		if n == 3 {
+			if v[2] <= 0 {
+				return errorAt(args[2], "invalid figure size %s", args[2].text)
+			}
+			fig.Size = max(scale(v[2], min(size.X, size.Y)), 1)
+		}
+		if existing := p.findFigure(id); existing != nil {
+			// Повторне оголошення іменованої фігури змінює її положення, розмір та колір.
+			*existing = *fig
+			break
 		}
 		p.figures = append(p.figures, fig)
 	case "move":
+		var figures []*painter.Figure
+		if len(args) > 0 && !isNumber(args[0].text) {
+			fig, err := p.namedFigure(args[0])
+			if err != nil {
+				return err
+			}
+			figures, args = []*painter.Figure{fig}, args[1:]
+		} else {
+			figures = p.figures
+		}
 		if len(args) != 2 {
-			return fmt.Errorf("move requires 2 arguments, got %d", len(args))
+			return errorAt(cmd, "move requires 2 arguments, got %d", len(args))
 		}
-		x, err1 := strconv.ParseFloat(args[0], 64)
-		y, err2 := strconv.ParseFloat(args[1], 64)
-
-		if err1 != nil || err2 != nil {
-			return fmt.Errorf("invalid arguments for move")
+		v, err := parseFloats(args)
+		if err != nil {
+			return err
 		}
 
 		// Конвертуємо нормалізовані координати у пікселі
 		moveOp := &painter.Move{
-			X:       int(x * 800),
-			Y:       int(y * 800),
-			Figures: p.figures,
+			X:       scale(v[0], size.X),
+			Y:       scale(v[1], size.Y),
+			Figures: figures,
 		}
 		p.moveOps = append(p.moveOps, moveOp)
+	case "animate":
+		if len(args) != 4 && len(args) != 5 {
+			return errorAt(cmd, "animate requires 4 or 5 arguments, got %d", len(args))
+		}
+		fig, perr := p.namedFigure(args[0])
+		if perr != nil {
+			return perr
+		}
+		v, perr := parseFloats(args[1:4])
+		if perr != nil {
+			return perr
+		}
+		if v[2] < 0 {
+			return errorAt(args[3], "invalid duration %s", args[3].text)
+		}
+		if v[2] > float64(maxAnimationDuration/time.Millisecond) {
+			return errorAt(args[3], "duration %s exceeds %s", args[3].text, maxAnimationDuration)
+		}
+		ease := painter.Linear
+		if len(args) == 5 {
+			var ok bool
+			if ease, ok = easings[args[4].text]; !ok {
+				return errorAt(args[4], "unknown easing %s", args[4].text)
+			}
+		}
+
+		// Кадри анімації починаються з моменту поточного відрізка скрипта і не зсувають наступні команди,
+		// тож кілька анімацій можуть виконуватись одночасно.
+		a := &painter.Animation{
+			X:        scale(v[0], size.X),
+			Y:        scale(v[1], size.Y),
+			ID:       fig.ID,
+			Duration: time.Duration(v[2] * float64(time.Millisecond)),
+			Easing:   ease,
+		}
+		if p.stage == nil {
+			p.stage = &painter.Stage{}
+		}
+		begin, frames := a.Frames(p.stage, p.start.Add(p.offset))
+		p.begins = append(p.begins, begin)
+		p.frames = append(p.frames, frames)
+		// Фігура парсера одразу займає кінцеве положення, тож історія та збережені сцени не залежать від того,
+		// скільки кадрів анімації вже виконано. Кадри перемальовують поточну сцену циклу подій, тож команди,
+		// надіслані під час анімації, не відкидаються.
+		fig.X += a.X
+		fig.Y += a.Y
+	case "delete":
+		if len(args) != 1 {
+			return errorAt(cmd, "delete requires 1 argument, got %d", len(args))
+		}
+		fig, err := p.namedFigure(args[0])
+		if err != nil {
+			return err
+		}
+		p.removeFigure(fig)
+	case "color":
+		if len(args) != 2 {
+			return errorAt(cmd, "color requires 2 arguments, got %d", len(args))
+		}
+		fig, perr := p.namedFigure(args[0])
+		if perr != nil {
+			return perr
+		}
+		c, err := parseColor(args[1].text)
+		if err != nil {
+			return wrapAt(args[1], err)
+		}
+		fig.C = color.RGBAModel.Convert(c).(color.RGBA)
+	case "circle":
+		if len(args) < 3 || len(args) > 7 {
+			return errorAt(cmd, "circle requires 3 to 7 arguments, got %d", len(args))
+		}
+		v, err := parseFloats(args[:3])
+		if err != nil {
+			return err
+		}
+		st, err := parseStyle(args[3:], styleOutline|styleWidth|styleOpacity)
+		if err != nil {
+			return err
+		}
+		p.shapes = append(p.shapes, &painter.Ellipse{
+			X:       scale(v[0], size.X),
+			Y:       scale(v[1], size.Y),
+			RX:      scale(v[2], min(size.X, size.Y)),
+			RY:      scale(v[2], min(size.X, size.Y)),
+			C:       st.colorOr(defaultShapeColor),
+			Outline: st.outline,
+			Width:   st.width,
+		})
+	case "ellipse":
+		if len(args) < 4 || len(args) > 8 {
+			return errorAt(cmd, "ellipse requires 4 to 8 arguments, got %d", len(args))
+		}
+		v, err := parseFloats(args[:4])
+		if err != nil {
+			return err
+		}
+		st, err := parseStyle(args[4:], styleOutline|styleWidth|styleOpacity)
+		if err != nil {
+			return err
+		}
+		p.shapes = append(p.shapes, &painter.Ellipse{
+			X:       scale(v[0], size.X),
+			Y:       scale(v[1], size.Y),
+			RX:      scale(v[2], size.X),
+			RY:      scale(v[3], size.Y),
+			C:       st.colorOr(defaultShapeColor),
+			Outline: st.outline,
+			Width:   st.width,
+		})
+	case "line":
+		if len(args) < 4 || len(args) > 7 {
+			return errorAt(cmd, "line requires 4 to 7 arguments, got %d", len(args))
+		}
+		v, err := parseFloats(args[:4])
+		if err != nil {
+			return err
+		}
+		st, err := parseStyle(args[4:], styleWidth|styleOpacity)
+		if err != nil {
+			return err
+		}
+		p.shapes = append(p.shapes, &painter.Line{
+			X1:    scale(v[0], size.X),
+			Y1:    scale(v[1], size.Y),
+			X2:    scale(v[2], size.X),
+			Y2:    scale(v[3], size.Y),
+			C:     st.colorOr(defaultShapeColor),
+			Width: st.width,
+		})
+	case "polyline":
+		pts, rest, err := parsePoints(cmd, args, 2, size)
+		if err != nil {
+			return err
+		}
+		st, err := parseStyle(rest, styleWidth|styleOpacity)
+		if err != nil {
+			return err
+		}
+		p.shapes = append(p.shapes, &painter.Polyline{Points: pts, C: st.colorOr(defaultShapeColor), Width: st.width})
+	case "polygon":
+		pts, rest, err := parsePoints(cmd, args, 3, size)
+		if err != nil {
+			return err
+		}
+		st, err := parseStyle(rest, styleOpacity)
+		if err != nil {
+			return err
+		}
+		p.shapes = append(p.shapes, &painter.Polygon{Points: pts, C: st.colorOr(defaultShapeColor)})
+	case "text":
+		if len(args) < 4 || len(args) > 6 {
+			return errorAt(cmd, "text requires 4 to 6 arguments, got %d", len(args))
+		}
+		v, err := parseFloats(args[:3])
+		if err != nil {
+			return err
+		}
+		if v[2] <= 0 {
+			return errorAt(args[2], "invalid text size %s", args[2].text)
+		}
+		if scale(v[2], min(size.X, size.Y)) > painter.MaxTextSize {
+			return errorAt(args[2], "text size %s exceeds %d pixels", args[2].text, painter.MaxTextSize)
+		}
+		str, err := parseString(args[3])
+		if err != nil {
+			return err
+		}
+		st, err := parseStyle(args[4:], styleOpacity)
+		if err != nil {
+			return err
+		}
+
+		// Висота тексту задається відносно меншої сторони полотна.
+		p.shapes = append(p.shapes, &painter.Text{
+			X:    scale(v[0], size.X),
+			Y:    scale(v[1], size.Y),
+			Size: max(scale(v[2], min(size.X, size.Y)), 1),
+			S:    str,
+			C:    st.colorOr(color.Black),
+		})
+	case "image":
+		if len(args) < 3 {
+			return errorAt(cmd, "image requires 3 to 6 arguments, got %d", len(args))
+		}
+		var img image.Image
+		if p.Assets != nil {
+			img, _ = p.Assets.Get(args[0].text)
+		}
+		if img == nil {
+			return errorAt(args[0], "unknown asset %s", args[0].text)
+		}
+		n := 2
+		if len(args) > 4 && isNumber(args[3].text) {
+			n = 4 // розмір зображення
+		}
+		if len(args) > n+2 {
+			return errorAt(cmd, "image requires 3 to 6 arguments, got %d", len(args))
+		}
+		v, err := parseFloats(args[1 : n+1])
+		if err != nil {
+			return err
+		}
+		st, err := parseStyle(args[n+1:], styleOpacity)
+		if err != nil {
+			return err
+		}
+		if st.color != nil {
+			return errorAt(args[n+1], "image does not take a color")
+		}
+		op := &painter.Image{Name: args[0].text, X: scale(v[0], size.X), Y: scale(v[1], size.Y), Img: img}
+		if st.opacity < 1 {
+			op.Opacity = st.opacity
+		}
+		if n == 4 {
+			if v[2] <= 0 || v[3] <= 0 {
+				return errorAt(args[3], "invalid image size %s %s", args[3].text, args[4].text)
+			}
+			op.W, op.H = max(scale(v[2], size.X), 1), max(scale(v[3], size.Y), 1)
+		}
+		p.shapes = append(p.shapes, op)
 	case "reset":
 		p.resetState()
 		p.lastBgColor = painter.OperationFunc(painter.ResetScreen)
+		p.background = instruction
+	case "save", "load":
+		if len(args) != 1 {
+			return errorAt(cmd, "%s requires 1 argument, got %d", instruction, len(args))
+		}
+		path, err := p.scenePath(args[0].text)
+		if err == nil && instruction == "save" {
+			err = p.saveScene(path)
+		} else if err == nil {
+			err = p.loadScene(path)
+		}
+		if err != nil {
+			return wrapAt(args[0], err)
+		}
 	default:
-		return fmt.Errorf("unknown command: %s", instruction)
+		return errorAt(cmd, "unknown command: %s", instruction)
 	}
 
+	p.dirty = true
 	return nil
 }
+
+// commit запам'ятовує поточний стан сцени в історії, якщо він змінився з моменту попереднього update.
+func (p *Parser) commit() {
+	s := p.scene()
+	if n := len(p.history); n > 0 && reflect.DeepEqual(p.history[n-1], s) {
+		return
+	}
+	p.history = append(p.history, s)
+	p.undone = nil
+
+	limit := p.HistoryLimit
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if over := len(p.history) - limit; over > 0 {
+		p.history = append([]*Scene(nil), p.history[over:]...)
+	}
+}
+
+// undo повертає сцену до стану на момент попереднього update. Незбережені зміни після останнього update
+// відкидаються.
+func (p *Parser) undo() error {
+	n := len(p.history)
+	if n < 2 {
+		return errors.New("nothing to undo")
+	}
+	if err := p.setScene(p.history[n-2]); err != nil {
+		return err
+	}
+	p.undone = append(p.undone, p.history[n-1])
+	p.history = p.history[:n-1]
+	return nil
+}
+
+// redo повертає стан сцени, скасований командою undo.
+func (p *Parser) redo() error {
+	n := len(p.undone)
+	if n == 0 {
+		return errors.New("nothing to redo")
+	}
+	if err := p.setScene(p.undone[n-1]); err != nil {
+		return err
+	}
+	p.history = append(p.history, p.undone[n-1])
+	p.undone = p.undone[:n-1]
+	return nil
+}
+
+// findFigure повертає фігуру з заданим ідентифікатором або nil, якщо такої немає.
+func (p *Parser) findFigure(id string) *painter.Figure {
+	if id == "" {
+		return nil
+	}
+	for _, fig := range p.figures {
+		if fig.ID == id {
+			return fig
+		}
+	}
+	return nil
+}
+
+// namedFigure повертає фігуру з ідентифікатором id або помилку, якщо такої немає.
+func (p *Parser) namedFigure(id token) (*painter.Figure, *ParseError) {
+	fig := p.findFigure(id.text)
+	if fig == nil {
+		return nil, errorAt(id, "unknown figure %s", id.text)
+	}
+	return fig, nil
+}
+
+// removeFigure видаляє фігуру зі сцени.
+func (p *Parser) removeFigure(fig *painter.Figure) {
+	figures := make([]*painter.Figure, 0, len(p.figures))
+	for _, f := range p.figures {
+		if f != fig {
+			figures = append(figures, f)
+		}
+	}
+	p.figures = figures
+}
+
+// canvasSize повертає розмір полотна, на яке відображаються нормалізовані координати.
+func (p *Parser) canvasSize() image.Point {
+	if p.CanvasSize.X <= 0 || p.CanvasSize.Y <= 0 {
+		return painter.DefaultCanvasSize
+	}
+	return p.CanvasSize
+}
+
+// scale перетворює нормалізовану координату у пікселі для сторони полотна довжиною n.
+func scale(v float64, n int) int {
+	return int(v * float64(n))
+}
+
+// isNumber перевіряє, чи є аргумент числом.
+func isNumber(arg string) bool {
+	_, err := strconv.ParseFloat(arg, 64)
+	return err == nil
+}
+
+// easings закони руху, доступні в команді animate.
+var easings = map[string]painter.Easing{
+	"linear":      painter.Linear,
+	"ease-in":     painter.EaseIn,
+	"ease-out":    painter.EaseOut,
+	"ease-in-out": painter.EaseInOut,
+	"bounce":      painter.Bounce,
+}
+
+// defaultShapeColor колір фігур, для яких колір не вказано явно.
+var defaultShapeColor = color.RGBA{B: 255, A: 255}
+
+// parseFloats перетворює аргументи команди у скінченні числа.
+func parseFloats(args []token) ([]float64, *ParseError) {
+	res := make([]float64, len(args))
+	for i, arg := range args {
+		v, err := strconv.ParseFloat(arg.text, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, errorAt(arg, "invalid number %s", arg.text)
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+// parsePoints розбирає пари нормалізованих координат на початку аргументів команди cmd і повертає точки у пікселях
+// та решту аргументів. Команда вимагає щонайменше minPoints точок.
+func parsePoints(cmd token, args []token, minPoints int, size image.Point) ([]image.Point, []token, *ParseError) {
+	n := 0
+	for n < len(args) && isNumber(args[n].text) {
+		n++
+	}
+	if n%2 != 0 {
+		return nil, nil, errorAt(cmd, "%s requires coordinate pairs, got %d numbers", cmd.text, n)
+	}
+	if n/2 < minPoints {
+		return nil, nil, errorAt(cmd, "%s requires at least %d points, got %d", cmd.text, minPoints, n/2)
+	}
+	v, err := parseFloats(args[:n])
+	if err != nil {
+		return nil, nil, err
+	}
+	pts := make([]image.Point, n/2)
+	for i := range pts {
+		pts[i] = image.Pt(scale(v[2*i], size.X), scale(v[2*i+1], size.Y))
+	}
+	return pts, args[n:], nil
+}
+
+// Прапорці необов'язкових аргументів стилю, які підтримує команда.
+const (
+	styleOutline  = 1 << iota // ключові слова fill та outline
+	styleWidth                // товщина лінії у пікселях: width=N
+	styleOpacity              // непрозорість від 0 до 1: opacity=N
+	styleGradient             // заливка градієнтом: linear(x1, y1, x2, y2, c1, c2) або radial(x, y, r, c1, c2)
+)
+
+// style містить необов'язкові аргументи оформлення, які записуються після обов'язкових аргументів команди
+// у довільному порядку. Колір підтримують усі команди, решту — лише ті, що передали відповідний прапорець.
+type style struct {
+	color    color.Color
+	outline  bool
+	width    int
+	opacity  float64
+	gradient *gradient
+}
+
+// colorOr повертає заданий колір або def, якщо колір не вказано, з урахуванням непрозорості.
+func (st style) colorOr(def color.Color) color.Color {
+	c := st.color
+	if c == nil {
+		c = def
+	}
+	if st.opacity >= 1 {
+		return c
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	n.A = uint8(math.Round(float64(n.A) * st.opacity))
+	return n
+}
+
+// parseStyle розбирає необов'язкові аргументи стилю команди.
+func parseStyle(args []token, flags int) (style, *ParseError) {
+	st := style{opacity: 1}
+	for _, arg := range args {
+		switch {
+		case flags&styleOutline != 0 && arg.text == "fill":
+			st.outline = false
+		case flags&styleOutline != 0 && arg.text == "outline":
+			st.outline = true
+		case flags&styleWidth != 0 && strings.HasPrefix(arg.text, "width="):
+			w, err := strconv.Atoi(strings.TrimPrefix(arg.text, "width="))
+			if err != nil || w <= 0 {
+				return st, errorAt(arg, "invalid width %s", arg.text)
+			}
+			st.width = w
+		case flags&styleOpacity != 0 && strings.HasPrefix(arg.text, "opacity="):
+			o, err := strconv.ParseFloat(strings.TrimPrefix(arg.text, "opacity="), 64)
+			if err != nil || o < 0 || o > 1 {
+				return st, errorAt(arg, "invalid opacity %s", arg.text)
+			}
+			st.opacity = o
+		case flags&styleGradient != 0 && isGradient(arg.text):
+			g, err := parseGradient(arg.text)
+			if err != nil {
+				return st, wrapAt(arg, err)
+			}
+			st.gradient = g
+		default:
+			c, err := parseColor(arg.text)
+			if err != nil {
+				return st, errorAt(arg, "invalid style argument %s", arg.text)
+			}
+			st.color = c
+		}
+	}
+	return st, nil
+}
+
+// splitFields розбиває рядок команди на аргументи за пробілами, не розриваючи вирази в дужках,
+// наприклад "rgb(1, 2, 3)", та рядки в лапках, наприклад "\"hello world\"". Для кожного аргументу запам'ятовується
+// номер символу, з якого він починається.
+func splitFields(line string) []token {
+	var (
+		fields  []token
+		cur     strings.Builder
+		start   int
+		depth   int
+		quoted  bool
+		escaped bool
+	)
+	flush := func() {
+		if cur.Len() > 0 {
+			fields = append(fields, token{text: cur.String(), col: start})
+			cur.Reset()
+		}
+	}
+	col := 0
+	for _, r := range line {
+		col++
+		switch {
+		case quoted:
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == '"':
+				quoted = false
+			}
+		case r == '"':
+			quoted = true
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case depth == 0 && (r == ' ' || r == '\t' || r == '\r'):
+			flush()
+			continue
+		}
+		if cur.Len() == 0 {
+			start = col
+		}
+		cur.WriteRune(r)
+	}
+	flush()
+	return fields
+}
+
+// parseString розбирає рядок у лапках з екрануванням у стилі Go або одне слово без лапок.
+func parseString(tok token) (string, *ParseError) {
+	if !strings.HasPrefix(tok.text, `"`) {
+		return tok.text, nil
+	}
+	s, err := strconv.Unquote(tok.text)
+	if err != nil {
+		return "", errorAt(tok, "invalid string %s", tok.text)
+	}
+	return s, nil
+}
@@ -1,12 +1,17 @@
 package lang
 
 import (
+	"context"
+	"image"
 	"image/color"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sifes/kpi-3-lab3/painter"
+	"github.com/sifes/kpi-3-lab3/ui/headless"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/colornames"
 )
 
 func TestParser_Parse_BasicCommands(t *testing.T) {
@@ -134,6 +139,58 @@ func TestParser_Parse_StructCommands(t *testing.T) {
 				assert.True(t, foundMove, "Should find a Move operation")
 			},
 		},
+		{
+			name:    "colored figure and rectangle",
+			command: "bgrect 0.1 0.1 0.9 0.9 #ff000080\nfigure 0.5 0.5 rgb(0, 128, 0)",
+			check: func(t *testing.T, ops []painter.Operation) {
+				assert.Equal(t, 3, len(ops), "Expected 3 operations")
+
+				bgRect, ok := ops[1].(*painter.BgRectangle)
+				assert.True(t, ok, "Second op should be BgRectangle")
+				if ok {
+					assert.Equal(t, color.NRGBA{R: 0xff, A: 0x80}, bgRect.C)
+				}
+				figure, ok := ops[2].(*painter.Figure)
+				assert.True(t, ok, "Third op should be Figure")
+				if ok {
+					assert.Equal(t, color.RGBA{G: 128, A: 255}, figure.C)
+				}
+			},
+		},
+		{
+			name:    "circle",
+			command: "circle 0.5 0.5 0.1",
+			check: func(t *testing.T, ops []painter.Operation) {
+				assert.Equal(t, 2, len(ops), "Expected 2 operations")
+
+				ellipse, ok := ops[1].(*painter.Ellipse)
+				assert.True(t, ok, "Second op should be Ellipse")
+				if ok {
+					assert.Equal(t, 400, ellipse.X)
+					assert.Equal(t, 400, ellipse.Y)
+					assert.Equal(t, 80, ellipse.RX)
+					assert.Equal(t, 80, ellipse.RY)
+					assert.False(t, ellipse.Outline)
+				}
+			},
+		},
+		{
+			name:    "outlined ellipse",
+			command: "ellipse 0.5 0.25 0.2 0.1 outline",
+			check: func(t *testing.T, ops []painter.Operation) {
+				assert.Equal(t, 2, len(ops), "Expected 2 operations")
+
+				ellipse, ok := ops[1].(*painter.Ellipse)
+				assert.True(t, ok, "Second op should be Ellipse")
+				if ok {
+					assert.Equal(t, 400, ellipse.X)
+					assert.Equal(t, 200, ellipse.Y)
+					assert.Equal(t, 160, ellipse.RX)
+					assert.Equal(t, 80, ellipse.RY)
+					assert.True(t, ellipse.Outline)
+				}
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -187,6 +244,30 @@ func TestParser_Parse_InvalidCommands(t *testing.T) {
 			name:    "move with too few arguments",
 			command: "move 0.1",
 		},
+		{
+			name:    "circle with too few arguments",
+			command: "circle 0.5 0.5",
+		},
+		{
+			name:    "fill without color",
+			command: "fill",
+		},
+		{
+			name:    "figure with invalid color",
+			command: "figure 0.5 0.5 #12345",
+		},
+		{
+			name:    "ellipse with unknown style",
+			command: "ellipse 0.5 0.5 0.1 0.2 dotted",
+		},
+		{
+			name:    "circle with infinite radius",
+			command: "circle 0.5 0.5 Inf",
+		},
+		{
+			name:    "bgrect with NaN coordinate",
+			command: "bgrect NaN 0 1 1",
+		},
 	}
 
 	for _, tc := range tests {
@@ -198,3 +279,471 @@ func TestParser_Parse_InvalidCommands(t *testing.T) {
 		})
 	}
 }
+
+func TestParser_Parse_Fill(t *testing.T) {
+	parser := &Parser{}
+	ops, err := parser.Parse(strings.NewReader("fill rgba(0, 0, 255, 0.5)"))
+
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(ops)) {
+		fill, ok := ops[0].(*painter.ColorFill)
+		assert.True(t, ok, "Expected ColorFill")
+		if ok {
+			assert.Equal(t, color.NRGBA{B: 255, A: 128}, fill.C)
+		}
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want color.Color
+	}{
+		{"#f00", color.NRGBA{R: 0xff, A: 0xff}},
+		{"#0f08", color.NRGBA{G: 0xff, A: 0x88}},
+		{"#336699", color.NRGBA{R: 0x33, G: 0x66, B: 0x99, A: 0xff}},
+		{"#33669900", color.NRGBA{R: 0x33, G: 0x66, B: 0x99}},
+		{"rgb(1,2,3)", color.NRGBA{R: 1, G: 2, B: 3, A: 0xff}},
+		{"RGBA(1, 2, 3, 1)", color.NRGBA{R: 1, G: 2, B: 3, A: 0xff}},
+		{"navy", color.RGBA{B: 0x80, A: 0xff}},
+		{"transparent", color.NRGBA{}},
+	}
+	for _, tc := range tests {
+		c, err := parseColor(tc.in)
+		if assert.NoError(t, err, tc.in) {
+			assert.Equal(t, tc.want, c, tc.in)
+		}
+	}
+
+	for _, in := range []string{"#12", "#xyzxyz", "rgb(1,2)", "rgb(1,2,300)", "rgba(1,2,3,2)", "notacolor"} {
+		_, err := parseColor(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestParser_Parse_NamedFigures(t *testing.T) {
+	parser := &Parser{}
+	ops, err := parser.Parse(strings.NewReader("figure car 0.5 0.5\nfigure tree 0.2 0.2 green\nmove car 0.1 0"))
+	assert.NoError(t, err)
+
+	var (
+		move    *painter.Move
+		figures []*painter.Figure
+	)
+	for _, op := range ops {
+		switch op := op.(type) {
+		case *painter.Move:
+			move = op
+		case *painter.Figure:
+			figures = append(figures, op)
+		}
+	}
+	if assert.NotNil(t, move) && assert.Equal(t, 1, len(move.Figures)) {
+		assert.Equal(t, "car", move.Figures[0].ID)
+		assert.Equal(t, 80, move.X)
+	}
+	if assert.Equal(t, 2, len(figures)) {
+		assert.Equal(t, "car", figures[0].ID)
+		assert.Equal(t, "tree", figures[1].ID)
+		assert.Equal(t, color.RGBA{G: 0x80, A: 0xff}, figures[1].C)
+	}
+
+	posted := figures[0]
+	ops, err = parser.Parse(strings.NewReader("color car #ff0000\ndelete tree"))
+	assert.NoError(t, err)
+	figures = nil
+	for _, op := range ops {
+		if fig, ok := op.(*painter.Figure); ok {
+			figures = append(figures, fig)
+		}
+	}
+	if assert.Equal(t, 1, len(figures)) {
+		assert.Equal(t, "car", figures[0].ID)
+		assert.Equal(t, color.RGBA{R: 0xff, A: 0xff}, figures[0].C)
+		assert.Equal(t, 480, figures[0].X)
+	}
+	// Фігури, вже передані у цикл подій, не змінюються наступними командами.
+	assert.NotSame(t, posted, figures[0])
+	assert.Equal(t, painter.Figure{ID: "car", X: 400, Y: 400, C: color.RGBA{B: 255, A: 255}}, *posted)
+
+	for _, cmd := range []string{"move tree 0.1 0.1", "delete tree", "color bus red", "color car"} {
+		_, err := parser.Parse(strings.NewReader(cmd))
+		assert.Error(t, err, cmd)
+	}
+}
+
+func TestParser_Parse_ErrorPosition(t *testing.T) {
+	tests := []struct {
+		script string
+		want   ParseError
+	}{
+		{
+			script: "white\n\nbogus 1",
+			want:   ParseError{Line: 3, Column: 1, Token: "bogus", Message: "unknown command: bogus"},
+		},
+		{
+			script: "  bgrect 0.1 0.1 0.9",
+			want: ParseError{Line: 1, Column: 3, Token: "bgrect", Expected: "bgrect x1 y1 x2 y2 [color|linear(...)|radial(...)] [opacity=N]",
+				Message: "bgrect requires 4 to 6 arguments, got 3"},
+		},
+		{
+			script: "circle 0.5 0.5 0.1 rgb(1, 2, 999)",
+			want: ParseError{Line: 1, Column: 20, Token: "rgb(1, 2, 999)", Expected: "circle x y r [fill|outline] [color] [width=N] [opacity=N]",
+				Message: "invalid style argument rgb(1, 2, 999)"},
+		},
+		{
+			script: "move car 0.1 0.1",
+			want: ParseError{Line: 1, Column: 6, Token: "car", Expected: "move [id] dx dy",
+				Message: "unknown figure car"},
+		},
+	}
+
+	for _, tc := range tests {
+		parser := &Parser{}
+		_, err := parser.Parse(strings.NewReader(tc.script))
+
+		var pe *ParseError
+		if assert.ErrorAs(t, err, &pe, tc.script) {
+			if tc.want.Expected == "" {
+				tc.want.Expected = pe.Expected
+				assert.Contains(t, pe.Expected, "bgrect")
+			}
+			assert.Equal(t, tc.want, *pe, tc.script)
+		}
+	}
+}
+
+func TestParser_Parse_UndoRedo(t *testing.T) {
+	parser := &Parser{}
+	bgRect := func(ops []painter.Operation) *painter.BgRectangle {
+		for _, op := range ops {
+			if r, ok := op.(*painter.BgRectangle); ok {
+				return r
+			}
+		}
+		return nil
+	}
+
+	_, err := parser.Parse(strings.NewReader("white\nbgrect 0.1 0.1 0.2 0.2\nupdate"))
+	assert.NoError(t, err)
+	_, err = parser.Parse(strings.NewReader("bgrect 0.5 0.5 0.9 0.9\nupdate"))
+	assert.NoError(t, err)
+
+	// Помилково надісланий прямокутник без update також відкидається.
+	_, err = parser.Parse(strings.NewReader("bgrect 0 0 1 1"))
+	assert.NoError(t, err)
+
+	ops, err := parser.Parse(strings.NewReader("undo"))
+	assert.NoError(t, err)
+	assert.Equal(t, 80, bgRect(ops).X1)
+	assert.Equal(t, painter.UpdateOp, ops[len(ops)-1])
+
+	// Перше update теж можна скасувати: сцена повертається до початкового стану.
+	ops, err = parser.Parse(strings.NewReader("undo"))
+	assert.NoError(t, err)
+	assert.Nil(t, bgRect(ops))
+
+	_, err = parser.Parse(strings.NewReader("undo"))
+	assert.Error(t, err)
+
+	ops, err = parser.Parse(strings.NewReader("redo"))
+	assert.NoError(t, err)
+	assert.Equal(t, 80, bgRect(ops).X1)
+
+	ops, err = parser.Parse(strings.NewReader("redo"))
+	assert.NoError(t, err)
+	assert.Equal(t, 400, bgRect(ops).X1)
+
+	_, err = parser.Parse(strings.NewReader("redo"))
+	assert.Error(t, err)
+
+	// Нове update очищує історію скасованих станів.
+	_, err = parser.Parse(strings.NewReader("undo\nbgrect 0.3 0.3 0.4 0.4\nupdate"))
+	assert.NoError(t, err)
+	_, err = parser.Parse(strings.NewReader("redo"))
+	assert.Error(t, err)
+}
+
+func TestParser_Parse_ErrorKeepsState(t *testing.T) {
+	parser := &Parser{}
+	_, err := parser.Parse(strings.NewReader("figure car 0.5 0.5\nupdate"))
+	assert.NoError(t, err)
+	before := parser.Scene()
+
+	_, err = parser.Parse(strings.NewReader("move car 0.1 0\nupdate\nwait 10\ndelete car\nbogus"))
+	assert.Error(t, err)
+	assert.Equal(t, before, parser.Scene())
+	assert.Len(t, parser.history, 2)
+
+	ops, err := parser.Parse(strings.NewReader("undo"))
+	assert.NoError(t, err)
+	for _, op := range ops {
+		_, ok := op.(*painter.Figure)
+		assert.False(t, ok)
+	}
+}
+
+func TestParser_Parse_WaitAt(t *testing.T) {
+	parser := &Parser{}
+	ops, err := parser.Parse(strings.NewReader(
+		"green\nfigure 0.2 0.2\nupdate\nwait 500\nmove 0.1 0\nupdate\nat 1500\nmove 0 0.1\nupdate\nwait 200\nwait 300"))
+	assert.NoError(t, err)
+	assert.Len(t, ops, 5)
+	assert.Equal(t, painter.UpdateOp, ops[2])
+
+	first, ok := ops[3].(*painter.Scheduled)
+	assert.True(t, ok)
+	second, ok := ops[4].(*painter.Scheduled)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second, second.At.Sub(first.At))
+
+	list := first.Op.(painter.OperationList)
+	assert.IsType(t, &painter.Move{}, list[1])
+	assert.Equal(t, painter.UpdateOp, list[len(list)-1])
+
+	// Кожен відрізок малює власні копії фігур, тож пізніші команди не змінюють попередні кадри.
+	parser = &Parser{}
+	ops, err = parser.Parse(strings.NewReader("white\nfigure car 0.5 0.5 red\nupdate\nwait 300\ncolor car blue\nupdate"))
+	assert.NoError(t, err)
+	if assert.Len(t, ops, 4) {
+		assert.Equal(t, color.RGBA{R: 255, A: 255}, ops[1].(*painter.Figure).C)
+		list := ops[3].(*painter.Scheduled).Op.(painter.OperationList)
+		assert.Equal(t, color.RGBA{B: 255, A: 255}, list[len(list)-2].(*painter.Figure).C)
+	}
+
+	_, err = parser.Parse(strings.NewReader("wait 100\nat 50"))
+	assert.Error(t, err)
+	_, err = parser.Parse(strings.NewReader("wait -1"))
+	assert.Error(t, err)
+}
+
+func TestParser_Parse_Animate(t *testing.T) {
+	parser := &Parser{}
+	ops, err := parser.Parse(strings.NewReader("white\nfigure a 0.5 0.5\nupdate\nwait 100\nanimate a 0.1 0 50 ease-in-out"))
+	assert.NoError(t, err)
+
+	var segment, frames []*painter.Scheduled
+	for _, op := range ops {
+		if s, ok := op.(*painter.Scheduled); ok {
+			if _, ok := s.Op.(painter.OperationList); ok {
+				segment = append(segment, s)
+			} else {
+				frames = append(frames, s)
+			}
+		}
+	}
+	// Анімація займає одну операцію: перший кадр, який сам планує наступні.
+	if !assert.Len(t, segment, 1) || !assert.Len(t, frames, 1) {
+		return
+	}
+	assert.InDelta(t, 50*time.Millisecond/3, frames[0].At.Sub(segment[0].At), float64(time.Microsecond))
+
+	// Фігура парсера одразу займає кінцеве положення, а відрізок малює її на початку анімації.
+	tx, err := headless.Screen{}.NewTexture(painter.DefaultCanvasSize)
+	assert.NoError(t, err)
+	list := segment[0].Op.(painter.OperationList)
+	fig := list[len(list)-1].(*painter.Figure)
+	assert.Equal(t, 480, fig.X)
+	list.Do(tx)
+	assert.Equal(t, 400, fig.X)
+	assert.True(t, frames[0].Do(tx))
+
+	for _, script := range []string{
+		"animate b 0.1 0 50",
+		"figure c 0 0\nanimate c 0.1 0 50 wobble",
+		"animate c 0.1 0",
+		"figure c 0 0\nanimate c 0.1 0 300001",
+		"figure c 0 0\nanimate c 0.1 0 1e13",
+	} {
+		_, err = parser.Parse(strings.NewReader(script))
+		assert.Error(t, err, script)
+	}
+}
+
+func TestParser_Parse_AnimateWhileLooping(t *testing.T) {
+	var (
+		loop painter.Loop
+		rec  headless.Recorder
+	)
+	loop.Receiver = &rec
+	defer loop.StopAndWait()
+
+	// Обидва скрипти потрапляють у чергу до запуску циклу, тож друга сцена надходить, поки анімація ще триває.
+	parser := &Parser{}
+	start := time.Now()
+	ops, err := parser.Parse(strings.NewReader("white\nfigure a 0.5 0.5\nupdate\nanimate a 0.1 0 200"))
+	assert.NoError(t, err)
+	f, err := loop.Submit(context.Background(), painter.OperationList(ops), painter.PriorityNormal)
+	assert.NoError(t, err)
+
+	// Кадри перемальовують поточну сцену, тож зміни, надіслані під час анімації, не відкидаються.
+	ops, err = parser.Parse(strings.NewReader("green\ndelete a\nupdate"))
+	assert.NoError(t, err)
+	assert.NoError(t, loop.Post(painter.OperationList(ops)))
+	loop.Start(headless.Screen{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	// Future завершується лише після останнього кадру, який цикл запланував сам.
+	assert.NoError(t, f.Wait(ctx))
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	green := color.RGBA{G: 0xff, A: 0xff}
+	img := loop.Snapshot()
+	assert.Equal(t, green, img.RGBAAt(0, 0))
+	assert.Equal(t, green, img.RGBAAt(480, 395))
+}
+
+func TestParser_Parse_CanvasSize(t *testing.T) {
+	parser := &Parser{CanvasSize: image.Pt(400, 200)}
+	ops, err := parser.Parse(strings.NewReader("bgrect 0.5 0.5 1 1\ncircle 0.5 0.5 0.25\nellipse 0.5 0.5 0.25 0.25\nfigure 0.25 0.75"))
+	assert.NoError(t, err)
+	assert.Equal(t, &painter.BgRectangle{X1: 200, Y1: 100, X2: 400, Y2: 200, C: color.Black}, ops[1])
+	circle, ellipse := ops[2].(*painter.Ellipse), ops[3].(*painter.Ellipse)
+	assert.Equal(t, []int{200, 100, 50, 50}, []int{circle.X, circle.Y, circle.RX, circle.RY})
+	assert.Equal(t, []int{200, 100, 100, 50}, []int{ellipse.X, ellipse.Y, ellipse.RX, ellipse.RY})
+	fig := ops[4].(*painter.Figure)
+	assert.Equal(t, image.Pt(100, 150), image.Pt(fig.X, fig.Y))
+
+	// Сцена зберігає координати відносно сторін полотна, тож відновлюється на полотні іншого розміру.
+	other := &Parser{CanvasSize: image.Pt(800, 400)}
+	assert.NoError(t, other.SetScene(parser.Scene()))
+	ops, err = other.Parse(strings.NewReader("update"))
+	assert.NoError(t, err)
+	assert.Equal(t, 400, ops[1].(*painter.BgRectangle).X1)
+	assert.Equal(t, 200, ops[4].(*painter.Figure).X)
+}
+
+func TestParser_Parse_FigureSize(t *testing.T) {
+	parser := &Parser{CanvasSize: image.Pt(400, 200)}
+	ops, err := parser.Parse(strings.NewReader("figure a 0.5 0.5 0.5 red\nfigure 0.1 0.1 0.25\nfigure 0.2 0.2 navy"))
+	assert.NoError(t, err)
+	assert.Equal(t, 100, ops[1].(*painter.Figure).Size)
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, ops[1].(*painter.Figure).C)
+	assert.Equal(t, 50, ops[2].(*painter.Figure).Size)
+	assert.Equal(t, 0, ops[3].(*painter.Figure).Size)
+
+	scene := parser.Scene()
+	assert.Equal(t, 0.5, scene.Figures[0].Size)
+	assert.Zero(t, scene.Figures[2].Size)
+
+	for _, script := range []string{"figure 0.5 0.5 0 red", "figure 0.5 0.5 0.2 red blue green", "figure 0.5 0.5 0.2 0.1"} {
+		_, err = parser.Parse(strings.NewReader(script))
+		assert.Error(t, err, script)
+	}
+}
+
+func TestParser_Parse_Lines(t *testing.T) {
+	parser := &Parser{}
+	ops, err := parser.Parse(strings.NewReader("line 0 0 1 0.5 red width=3\npolyline 0 0 0.5 0.5 1 0 width=2 #00ff00\ncircle 0.5 0.5 0.1 outline width=5"))
+	assert.NoError(t, err)
+	assert.Equal(t, &painter.Line{X2: 800, Y2: 400, C: color.RGBA{R: 255, A: 255}, Width: 3}, ops[1])
+	assert.Equal(t, &painter.Polyline{
+		Points: []image.Point{{0, 0}, {400, 400}, {800, 0}},
+		C:      color.NRGBA{G: 255, A: 255},
+		Width:  2,
+	}, ops[2])
+	assert.Equal(t, 5, ops[3].(*painter.Ellipse).Width)
+
+	restored := &Parser{}
+	assert.NoError(t, restored.SetScene(parser.Scene()))
+	assert.Equal(t, parser.Scene(), restored.Scene())
+
+	for _, script := range []string{"line 0 0 1", "polyline 0 0 1", "polyline 0 0 1 1 0.5", "line 0 0 1 1 width=0", "bgrect 0 0 1 1 width=2"} {
+		_, err = parser.Parse(strings.NewReader(script))
+		assert.Error(t, err, script)
+	}
+}
+
+func TestParser_Parse_Polygon(t *testing.T) {
+	parser := &Parser{}
+	ops, err := parser.Parse(strings.NewReader("polygon 0 0 0.5 0 0.25 0.5 orange"))
+	assert.NoError(t, err)
+	assert.Equal(t, &painter.Polygon{Points: []image.Point{{0, 0}, {400, 0}, {200, 400}}, C: colornames.Orange}, ops[1])
+
+	restored := &Parser{}
+	assert.NoError(t, restored.SetScene(parser.Scene()))
+	assert.Equal(t, parser.Scene(), restored.Scene())
+
+	for _, script := range []string{"polygon 0 0 1 1", "polygon 0 0 1 1 0.5", "polygon 0 0 1 1 1 0 width=2", "polygon 0 0 1 0 0 Inf"} {
+		_, err = parser.Parse(strings.NewReader(script))
+		assert.Error(t, err, script)
+	}
+}
+
+func TestParser_Parse_Text(t *testing.T) {
+	parser := &Parser{}
+	ops, err := parser.Parse(strings.NewReader(`text 0.1 0.2 0.05 "Status: \"ok\" (1/2)" green`))
+	assert.NoError(t, err)
+	assert.Equal(t, &painter.Text{X: 80, Y: 160, Size: 40, S: `Status: "ok" (1/2)`, C: colornames.Green}, ops[1])
+
+	ops, err = parser.Parse(strings.NewReader("text 0 0 0.02 ready"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ready", ops[2].(*painter.Text).S)
+
+	restored := &Parser{}
+	assert.NoError(t, restored.SetScene(parser.Scene()))
+	assert.Equal(t, parser.Scene(), restored.Scene())
+
+	for _, script := range []string{`text 0 0 0.1`, `text 0 0 0.1 "open`, `text 0 0 0 "a"`, `text 0 0 0.1 "a" "b"`, `text 0 0 50 "W"`} {
+		_, err = parser.Parse(strings.NewReader(script))
+		assert.Error(t, err, script)
+	}
+}
+
+func TestParser_Parse_Opacity(t *testing.T) {
+	parser := &Parser{}
+	ops, err := parser.Parse(strings.NewReader("bgrect 0 0 1 1 red opacity=0.5\ncircle 0.5 0.5 0.1 opacity=0.25\nfigure 0.5 0.5 opacity=0"))
+	assert.NoError(t, err)
+	assert.Equal(t, color.NRGBA{R: 255, A: 128}, ops[1].(*painter.BgRectangle).C)
+	assert.Equal(t, color.NRGBA{B: 255, A: 64}, ops[2].(*painter.Ellipse).C)
+	assert.Equal(t, color.RGBA{}, ops[3].(*painter.Figure).C)
+
+	restored := &Parser{}
+	assert.NoError(t, restored.SetScene(parser.Scene()))
+	assert.Equal(t, parser.Scene(), restored.Scene())
+
+	for _, script := range []string{"bgrect 0 0 1 1 opacity=2", "line 0 0 1 1 opacity=x", "fill red opacity=0.5"} {
+		_, err = parser.Parse(strings.NewReader(script))
+		assert.Error(t, err, script)
+	}
+}
+
+func TestParser_Parse_Gradient(t *testing.T) {
+	parser := &Parser{}
+	ops, err := parser.Parse(strings.NewReader("gradient linear 0 0 1 0 white #000\nbgrect 0.25 0.25 0.75 0.75 radial(0.5, 0.5, 0.25, rgb(255, 0, 0), transparent) opacity=0.5"))
+	assert.NoError(t, err)
+	// Градієнти малюються один раз під час розбору, а операції накладають готові пікселі.
+	size := painter.DefaultCanvasSize
+	if assert.Equal(t, 2, len(ops)) {
+		linear := &painter.LinearGradient{X1: 0, Y1: 0, X2: 800, Y2: 0, From: color.RGBA{R: 255, G: 255, B: 255, A: 255}, To: color.NRGBA{A: 255}}
+		assert.Equal(t, painter.RenderPattern(linear, size), ops[0].(*painter.PatternFill).P)
+		rect := ops[1].(*painter.BgRectangle)
+		radial := &painter.RadialGradient{X: 400, Y: 400, R: 200, From: color.NRGBA{R: 255, A: 128}, To: color.NRGBA{}}
+		assert.Equal(t, painter.RenderPattern(radial, size), rect.Fill)
+	}
+
+	scene := parser.Scene()
+	assert.Equal(t, "linear(0,0,1,0,#ffffffff,#000000ff)", scene.Background)
+	assert.Equal(t, "radial(0.5,0.5,0.25,#ff000080,#00000000)", scene.Rect.Fill)
+	restored := &Parser{}
+	assert.NoError(t, restored.SetScene(scene))
+	assert.Equal(t, scene, restored.Scene())
+
+	ops, err = parser.Parse(strings.NewReader("gradient radial 0.5 0.5 0.5 red blue"))
+	assert.NoError(t, err)
+	radial := &painter.RadialGradient{X: 400, Y: 400, R: 400, From: color.RGBA{R: 255, A: 255}, To: color.RGBA{B: 255, A: 255}}
+	assert.Equal(t, painter.RenderPattern(radial, size), ops[0].(*painter.PatternFill).P)
+
+	for _, script := range []string{
+		"gradient",
+		"gradient conic 0 0 1 1 red blue",
+		"gradient linear 0 0 1 red blue",
+		"gradient radial 0.5 0.5 -1 red blue",
+		"gradient linear 0 0 1 1 red nope",
+		"bgrect 0 0 1 1 linear(0, 0, 1, red, blue)",
+		"circle 0.5 0.5 0.1 linear(0, 0, 1, 1, red, blue)",
+	} {
+		_, err = parser.Parse(strings.NewReader(script))
+		assert.Error(t, err, script)
+	}
+}
@@ -0,0 +1,402 @@
+package lang
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/sifes/kpi-3-lab3/painter"
+)
+
+// Scene описує стан полотна, який накопичує Parser, у вигляді, придатному для збереження у файл.
+// Координати зберігаються нормалізованими відносно ширини та висоти полотна, а кольори — у форматі #rrggbbaa.
+type Scene struct {
+	// Background містить фон: white, green, reset, колір, заданий командою fill, або градієнт у записі linear(...)
+	// чи radial(...).
+	Background string        `json:"background,omitempty"`
+	Rect       *SceneRect    `json:"rect,omitempty"`
+	Shapes     []SceneShape  `json:"shapes,omitempty"`
+	Figures    []SceneFigure `json:"figures,omitempty"`
+	// Moves містить переміщення, які ще не були відправлені у цикл подій.
+	Moves []SceneMove `json:"moves,omitempty"`
+}
+
+// SceneRect описує прямокутник на фоні.
+type SceneRect struct {
+	X1    float64 `json:"x1"`
+	Y1    float64 `json:"y1"`
+	X2    float64 `json:"x2"`
+	Y2    float64 `json:"y2"`
+	Color string  `json:"color"`
+	// Fill містить градієнт, яким зафарбовано прямокутник, у записі linear(...) або radial(...).
+	Fill string `json:"fill,omitempty"`
+}
+
+// SceneShape описує довільну фігуру сцени. Значення Coords залежать від Kind:
+// для "ellipse" це центр та два радіуси, для "line", "polyline" та "polygon" — координати точок x1, y1, x2, y2, ...,
+// для "text" — лівий верхній кут та висота тексту відносно меншої сторони полотна, для "image" — лівий верхній
+// кут та, якщо задано, розмір зображення.
+type SceneShape struct {
+	Kind    string    `json:"kind"`
+	Coords  []float64 `json:"coords"`
+	Color   string    `json:"color"`
+	Outline bool      `json:"outline,omitempty"`
+	// Width товщина лінії або контуру у пікселях. Нульове значення означає товщину за замовчуванням.
+	Width int `json:"width,omitempty"`
+	// Text рядок, який малює фігура "text", або ім'я ресурсу для фігури "image".
+	Text string `json:"text,omitempty"`
+	// Opacity непрозорість фігури "image". Непрозорість інших фігур зберігається в альфа-каналі Color.
+	Opacity float64 `json:"opacity,omitempty"`
+}
+
+// SceneFigure описує фігуру у вигляді літери "Т".
+type SceneFigure struct {
+	ID string  `json:"id,omitempty"`
+	X  float64 `json:"x"`
+	Y  float64 `json:"y"`
+	// Size ширина фігури відносно меншої сторони полотна. Нульове значення означає розмір за замовчуванням.
+	Size  float64 `json:"size,omitempty"`
+	Color string  `json:"color"`
+}
+
+// SceneMove описує відкладене переміщення фігур з індексами Figures у списку Scene.Figures.
+type SceneMove struct {
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	Figures []int   `json:"figures"`
+}
+
+// sceneName обмежує імена сцен у командах save та load, щоб вони не виходили за межі SceneDir, а також імена
+// ресурсів AssetStore.
+var sceneName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Scene повертає поточний стан парсера у вигляді сцени.
+func (p *Parser) Scene() *Scene {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.scene()
+}
+
+// SetScene замінює поточний стан парсера станом зі сцени.
+func (p *Parser) SetScene(s *Scene) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.setScene(s)
+}
+
+// SaveScene записує поточний стан парсера у файл path у форматі JSON.
+func (p *Parser) SaveScene(path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.saveScene(path)
+}
+
+// LoadScene замінює поточний стан парсера сценою, збереженою у файлі path.
+func (p *Parser) LoadScene(path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.loadScene(path)
+}
+
+func (p *Parser) scene() *Scene {
+	size := p.canvasSize()
+	s := &Scene{Background: p.background}
+	if r := p.lastBgRect; r != nil {
+		s.Rect = &SceneRect{
+			X1:    norm(r.X1, size.X),
+			Y1:    norm(r.Y1, size.Y),
+			X2:    norm(r.X2, size.X),
+			Y2:    norm(r.Y2, size.Y),
+			Color: formatColor(r.C),
+		}
+		if p.rectFill != nil {
+			s.Rect.Fill = p.rectFill.String()
+		}
+	}
+	for _, op := range p.shapes {
+		switch op := op.(type) {
+		case *painter.Ellipse:
+			s.Shapes = append(s.Shapes, SceneShape{
+				Kind:    "ellipse",
+				Coords:  []float64{norm(op.X, size.X), norm(op.Y, size.Y), norm(op.RX, size.X), norm(op.RY, size.Y)},
+				Color:   formatColor(op.C),
+				Outline: op.Outline,
+				Width:   op.Width,
+			})
+		case *painter.Line:
+			s.Shapes = append(s.Shapes, SceneShape{
+				Kind:   "line",
+				Coords: []float64{norm(op.X1, size.X), norm(op.Y1, size.Y), norm(op.X2, size.X), norm(op.Y2, size.Y)},
+				Color:  formatColor(op.C),
+				Width:  op.Width,
+			})
+		case *painter.Polyline:
+			s.Shapes = append(s.Shapes, SceneShape{
+				Kind:   "polyline",
+				Coords: normPoints(op.Points, size),
+				Color:  formatColor(op.C),
+				Width:  op.Width,
+			})
+		case *painter.Text:
+			s.Shapes = append(s.Shapes, SceneShape{
+				Kind:   "text",
+				Coords: []float64{norm(op.X, size.X), norm(op.Y, size.Y), norm(op.Size, min(size.X, size.Y))},
+				Color:  formatColor(op.C),
+				Text:   op.S,
+			})
+		case *painter.Image:
+			coords := []float64{norm(op.X, size.X), norm(op.Y, size.Y)}
+			if op.W > 0 && op.H > 0 {
+				coords = append(coords, norm(op.W, size.X), norm(op.H, size.Y))
+			}
+			s.Shapes = append(s.Shapes, SceneShape{
+				Kind:    "image",
+				Coords:  coords,
+				Color:   formatColor(nil),
+				Text:    op.Name,
+				Opacity: op.Opacity,
+			})
+		case *painter.Polygon:
+			s.Shapes = append(s.Shapes, SceneShape{
+				Kind:   "polygon",
+				Coords: normPoints(op.Points, size),
+				Color:  formatColor(op.C),
+			})
+		}
+	}
+
+	index := make(map[*painter.Figure]int, len(p.figures))
+	for i, fig := range p.figures {
+		index[fig] = i
+		s.Figures = append(s.Figures, SceneFigure{
+			ID:    fig.ID,
+			X:     norm(fig.X, size.X),
+			Y:     norm(fig.Y, size.Y),
+			Size:  norm(fig.Size, min(size.X, size.Y)),
+			Color: formatColor(fig.C),
+		})
+	}
+	for _, op := range p.moveOps {
+		move, ok := op.(*painter.Move)
+		if !ok {
+			continue
+		}
+		sm := SceneMove{X: norm(move.X, size.X), Y: norm(move.Y, size.Y), Figures: []int{}}
+		for _, fig := range move.Figures {
+			if i, ok := index[fig]; ok {
+				sm.Figures = append(sm.Figures, i)
+			}
+		}
+		s.Moves = append(s.Moves, sm)
+	}
+	return s
+}
+
+func (p *Parser) setScene(s *Scene) error {
+	size := p.canvasSize()
+	var q Parser
+	if s.Background != "" {
+		bg, err := backgroundOp(s.Background, size)
+		if err != nil {
+			return err
+		}
+		q.lastBgColor, q.background = bg, s.Background
+	}
+	if r := s.Rect; r != nil {
+		c, err := parseColor(r.Color)
+		if err != nil {
+			return err
+		}
+		q.lastBgRect = &painter.BgRectangle{X1: px(r.X1, size.X), Y1: px(r.Y1, size.Y), X2: px(r.X2, size.X), Y2: px(r.Y2, size.Y), C: c}
+		if r.Fill != "" {
+			g, err := parseGradient(r.Fill)
+			if err != nil {
+				return err
+			}
+			q.lastBgRect.Fill, q.rectFill = g.image(size, px), g
+		}
+	}
+	for _, sh := range s.Shapes {
+		c, err := parseColor(sh.Color)
+		if err != nil {
+			return err
+		}
+		switch {
+		case sh.Kind == "ellipse" && len(sh.Coords) == 4:
+			q.shapes = append(q.shapes, &painter.Ellipse{
+				X:       px(sh.Coords[0], size.X),
+				Y:       px(sh.Coords[1], size.Y),
+				RX:      px(sh.Coords[2], size.X),
+				RY:      px(sh.Coords[3], size.Y),
+				C:       c,
+				Outline: sh.Outline,
+				Width:   sh.Width,
+			})
+		case sh.Kind == "line" && len(sh.Coords) == 4:
+			q.shapes = append(q.shapes, &painter.Line{
+				X1:    px(sh.Coords[0], size.X),
+				Y1:    px(sh.Coords[1], size.Y),
+				X2:    px(sh.Coords[2], size.X),
+				Y2:    px(sh.Coords[3], size.Y),
+				C:     c,
+				Width: sh.Width,
+			})
+		case sh.Kind == "polyline" && len(sh.Coords) >= 4 && len(sh.Coords)%2 == 0:
+			q.shapes = append(q.shapes, &painter.Polyline{Points: pxPoints(sh.Coords, size), C: c, Width: sh.Width})
+		case sh.Kind == "text" && len(sh.Coords) == 3:
+			q.shapes = append(q.shapes, &painter.Text{
+				X:    px(sh.Coords[0], size.X),
+				Y:    px(sh.Coords[1], size.Y),
+				Size: px(sh.Coords[2], min(size.X, size.Y)),
+				S:    sh.Text,
+				C:    c,
+			})
+		case sh.Kind == "image" && (len(sh.Coords) == 2 || len(sh.Coords) == 4):
+			// Ресурс може бути завантажений пізніше, тож зображення без ресурсу просто не малюється.
+			op := &painter.Image{
+				Name:    sh.Text,
+				X:       px(sh.Coords[0], size.X),
+				Y:       px(sh.Coords[1], size.Y),
+				Opacity: sh.Opacity,
+			}
+			if len(sh.Coords) == 4 {
+				op.W, op.H = px(sh.Coords[2], size.X), px(sh.Coords[3], size.Y)
+			}
+			if p.Assets != nil {
+				op.Img, _ = p.Assets.Get(sh.Text)
+			}
+			q.shapes = append(q.shapes, op)
+		case sh.Kind == "polygon" && len(sh.Coords) >= 6 && len(sh.Coords)%2 == 0:
+			q.shapes = append(q.shapes, &painter.Polygon{Points: pxPoints(sh.Coords, size), C: c})
+		default:
+			return fmt.Errorf("invalid scene shape: %s", sh.Kind)
+		}
+	}
+	for _, sf := range s.Figures {
+		c, err := parseColor(sf.Color)
+		if err != nil {
+			return err
+		}
+		q.figures = append(q.figures, &painter.Figure{
+			ID:   sf.ID,
+			X:    px(sf.X, size.X),
+			Y:    px(sf.Y, size.Y),
+			Size: px(sf.Size, min(size.X, size.Y)),
+			C:    color.RGBAModel.Convert(c).(color.RGBA),
+		})
+	}
+	for _, sm := range s.Moves {
+		move := &painter.Move{X: px(sm.X, size.X), Y: px(sm.Y, size.Y)}
+		for _, i := range sm.Figures {
+			if i < 0 || i >= len(q.figures) {
+				return fmt.Errorf("invalid scene move: no figure %d", i)
+			}
+			move.Figures = append(move.Figures, q.figures[i])
+		}
+		q.moveOps = append(q.moveOps, move)
+	}
+
+	p.resetState()
+	p.background = q.background
+	p.lastBgColor = q.lastBgColor
+	p.lastBgRect = q.lastBgRect
+	p.rectFill = q.rectFill
+	p.shapes = q.shapes
+	p.figures = q.figures
+	p.moveOps = q.moveOps
+	return nil
+}
+
+func (p *Parser) saveScene(path string) error {
+	data, err := json.MarshalIndent(p.scene(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (p *Parser) loadScene(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var s Scene
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid scene file %s: %w", path, err)
+	}
+	return p.setScene(&s)
+}
+
+// scenePath повертає шлях до файлу сцени з іменем name у каталозі SceneDir.
+func (p *Parser) scenePath(name string) (string, error) {
+	if !sceneName.MatchString(name) {
+		return "", fmt.Errorf("invalid scene name: %s", name)
+	}
+	return filepath.Join(p.SceneDir, name+".json"), nil
+}
+
+// backgroundOp повертає операцію, яка малює фон, описаний у Scene.Background, на полотні розміром size.
+func backgroundOp(bg string, size image.Point) (painter.Operation, error) {
+	switch bg {
+	case "white":
+		return painter.OperationFunc(painter.WhiteFill), nil
+	case "green":
+		return painter.OperationFunc(painter.GreenFill), nil
+	case "reset":
+		return painter.OperationFunc(painter.ResetScreen), nil
+	}
+	if isGradient(bg) {
+		g, err := parseGradient(bg)
+		if err != nil {
+			return nil, err
+		}
+		return &painter.PatternFill{P: g.image(size, px)}, nil
+	}
+	c, err := parseColor(bg)
+	if err != nil {
+		return nil, err
+	}
+	return &painter.ColorFill{C: c}, nil
+}
+
+// formatColor записує колір у форматі #rrggbbaa.
+func formatColor(c color.Color) string {
+	if c == nil {
+		c = color.Black
+	}
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x%02x", n.R, n.G, n.B, n.A)
+}
+
+// norm перетворює координату у пікселях у нормалізовану для сторони полотна довжиною n.
+func norm(v, n int) float64 {
+	return float64(v) / float64(n)
+}
+
+// normPoints записує точки у пікселях як послідовність нормалізованих координат x1, y1, x2, y2, ...
+func normPoints(pts []image.Point, size image.Point) []float64 {
+	res := make([]float64, 0, 2*len(pts))
+	for _, pt := range pts {
+		res = append(res, norm(pt.X, size.X), norm(pt.Y, size.Y))
+	}
+	return res
+}
+
+// pxPoints перетворює послідовність нормалізованих координат, отриману через normPoints, назад у точки.
+func pxPoints(coords []float64, size image.Point) []image.Point {
+	res := make([]image.Point, len(coords)/2)
+	for i := range res {
+		res[i] = image.Pt(px(coords[2*i], size.X), px(coords[2*i+1], size.Y))
+	}
+	return res
+}
+
+// px перетворює нормалізовану координату, отриману через norm, назад у пікселі.
+func px(v float64, n int) int {
+	return int(math.Round(v * float64(n)))
+}
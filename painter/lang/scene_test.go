@@ -0,0 +1,97 @@
+package lang
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sifes/kpi-3-lab3/painter"
+	"github.com/sifes/kpi-3-lab3/ui/headless"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParser_SaveLoad(t *testing.T) {
+	script := `
+fill #336699
+bgrect 0.25 0.25 0.75 0.75 red
+circle 0.5 0.5 0.1 outline white
+figure car 0.5 0.5
+figure 0.2 0.2 green
+move car 0.1 0
+save demo
+`
+	src := &Parser{SceneDir: t.TempDir()}
+	_, err := src.Parse(strings.NewReader(script))
+	assert.NoError(t, err)
+
+	dst := &Parser{SceneDir: src.SceneDir}
+	ops, err := dst.Parse(strings.NewReader("load demo"))
+	assert.NoError(t, err)
+	assert.Equal(t, src.Scene(), dst.Scene())
+
+	if assert.Equal(t, 6, len(ops)) {
+		fill, ok := ops[0].(*painter.ColorFill)
+		if assert.True(t, ok, "First op should be ColorFill") {
+			assert.Equal(t, color.NRGBA{R: 0x33, G: 0x66, B: 0x99, A: 0xff}, fill.C)
+		}
+		rect, ok := ops[1].(*painter.BgRectangle)
+		if assert.True(t, ok, "Second op should be BgRectangle") {
+			assert.Equal(t, painter.BgRectangle{X1: 200, Y1: 200, X2: 600, Y2: 600, C: color.NRGBA{R: 0xff, A: 0xff}}, *rect)
+		}
+		ellipse, ok := ops[2].(*painter.Ellipse)
+		if assert.True(t, ok, "Third op should be Ellipse") {
+			assert.True(t, ellipse.Outline)
+			assert.Equal(t, 80, ellipse.RX)
+		}
+		move, ok := ops[3].(*painter.Move)
+		if assert.True(t, ok, "Fourth op should be Move") && assert.Equal(t, 1, len(move.Figures)) {
+			assert.Same(t, ops[4], painter.Operation(move.Figures[0]))
+		}
+		car, ok := ops[4].(*painter.Figure)
+		if assert.True(t, ok, "Fifth op should be Figure") {
+			assert.Equal(t, painter.Figure{ID: "car", X: 400, Y: 400, C: color.RGBA{B: 255, A: 255}}, *car)
+		}
+	}
+
+	for _, cmd := range []string{"save ../escape", "load missing", "save"} {
+		_, err := dst.Parse(strings.NewReader(cmd))
+		assert.Error(t, err, cmd)
+	}
+}
+
+func TestParser_Scene_WhileLooping(t *testing.T) {
+	var (
+		loop painter.Loop
+		rec  headless.Recorder
+	)
+	loop.Receiver = &rec
+	loop.Start(headless.Screen{})
+	defer loop.StopAndWait()
+
+	parser := &Parser{SceneDir: t.TempDir()}
+	for _, script := range []string{
+		"white\nfigure car 0.1 0.1\nanimate car 0.5 0 100\nupdate",
+		"move car 0 0.1\nupdate",
+		"color car red\nmove car 0 0.1\nupdate\nsave demo",
+	} {
+		ops, err := parser.Parse(strings.NewReader(script))
+		assert.NoError(t, err)
+		assert.NoError(t, loop.Post(painter.OperationList(ops)))
+	}
+
+	// Історія та збережена сцена містять кінцеве положення фігури незалежно від того, скільки кадрів анімації та
+	// переміщень цикл подій уже виконав.
+	s := parser.Scene()
+	if assert.Equal(t, 1, len(s.Figures)) {
+		assert.InDelta(t, 0.6, s.Figures[0].X, 1e-9)
+		assert.InDelta(t, 0.3, s.Figures[0].Y, 1e-9)
+	}
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, s, parser.Scene())
+
+	loaded := &Parser{SceneDir: parser.SceneDir}
+	_, err := loaded.Parse(strings.NewReader("load demo"))
+	assert.NoError(t, err)
+	assert.Equal(t, s, loaded.Scene())
+}
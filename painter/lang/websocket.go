@@ -0,0 +1,81 @@
+package lang
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/sifes/kpi-3-lab3/painter"
+	"golang.org/x/net/websocket"
+)
+
+// Ack підтверджує обробку одного рядка команд, отриманого через WebSocket.
+type Ack struct {
+	// Seq порядковий номер рядка в межах з'єднання, починаючи з 1.
+	Seq   int    `json:"seq"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	// Details містить позицію помилки, якщо рядок не вдалося розібрати. Line вказує номер рядка у повідомленні.
+	Details *ParseError `json:"details,omitempty"`
+}
+
+// timedCommands команди затримки, які не приймає WebSocketHandler.
+var timedCommands = map[string]bool{"wait": true, "at": true}
+
+// WebSocketHandler конструює обробник WebSocket з'єднань. Клієнт надсилає текстові повідомлення з одним або
+// кількома рядками команд, кожен рядок окремо віддається у Parser, а отриманий список операцій відправляється
+// у painter.Loop. На кожен непорожній рядок клієнт отримує Ack у форматі JSON. Команди wait та at відхиляються,
+// бо затримка діє лише в межах одного скрипта.
+func WebSocketHandler(loop *painter.Loop, p *Parser) http.Handler {
+	return websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+
+		seq := 0
+		for {
+			var msg string
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				if err != io.EOF {
+					log.Printf("WebSocket receive failed: %s", err)
+				}
+				return
+			}
+
+			scanner := bufio.NewScanner(strings.NewReader(msg))
+			for n := 1; scanner.Scan(); n++ {
+				line := scanner.Text()
+				if len(strings.TrimSpace(line)) == 0 {
+					continue
+				}
+				seq++
+				ack := Ack{Seq: seq, OK: true}
+
+				var (
+					cmds []painter.Operation
+					err  error
+				)
+				if cmd := splitFields(line)[0]; timedCommands[cmd.text] {
+					// Кожен рядок розбирається окремо, тож затримка не поширилася б на наступні рядки.
+					err = errorAt(cmd, "%s is not supported over WebSocket, send timed scripts over HTTP", cmd.text)
+				} else {
+					cmds, err = p.Parse(strings.NewReader(line))
+				}
+				if err != nil {
+					ack = Ack{Seq: seq, Error: err.Error()}
+					if pe, ok := err.(*ParseError); ok {
+						pe.Line = n
+						ack.Error, ack.Details = pe.Error(), pe
+					}
+				} else if err := loop.Post(painter.OperationList(cmds)); err != nil {
+					ack = Ack{Seq: seq, Error: err.Error()}
+				}
+
+				if err := websocket.JSON.Send(ws, ack); err != nil {
+					log.Printf("WebSocket send failed: %s", err)
+					return
+				}
+			}
+		}
+	}}
+}
@@ -0,0 +1,82 @@
+package lang
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sifes/kpi-3-lab3/painter"
+	"github.com/sifes/kpi-3-lab3/ui/headless"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+func TestWebSocketHandler(t *testing.T) {
+	var (
+		loop   painter.Loop
+		parser Parser
+		rec    headless.Recorder
+	)
+	loop.Receiver = &rec
+	loop.Start(headless.Screen{})
+	defer loop.StopAndWait()
+
+	srv := httptest.NewServer(WebSocketHandler(&loop, &parser))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	ws, err := websocket.Dial(url, "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	assert.NoError(t, websocket.Message.Send(ws, "white\n\nfigure 0.5 0.5"))
+	assert.NoError(t, websocket.Message.Send(ws, "white\n  bgrect 0.1 x 0.2 0.2"))
+	assert.NoError(t, websocket.Message.Send(ws, "update"))
+	assert.NoError(t, websocket.Message.Send(ws, "wait 500\nat 100\ngreen"))
+
+	want := []Ack{
+		{Seq: 1, OK: true},
+		{Seq: 2, OK: true},
+		{Seq: 3, OK: true},
+		{
+			Seq:   4,
+			Error: "line 2, column 14: invalid number x; expected: bgrect x1 y1 x2 y2 [color|linear(...)|radial(...)] [opacity=N]",
+			Details: &ParseError{
+				Line:     2,
+				Column:   14,
+				Token:    "x",
+				Expected: "bgrect x1 y1 x2 y2 [color|linear(...)|radial(...)] [opacity=N]",
+				Message:  "invalid number x",
+			},
+		},
+		{Seq: 5, OK: true},
+		{
+			Seq:   6,
+			Error: "line 1, column 1: wait is not supported over WebSocket, send timed scripts over HTTP",
+			Details: &ParseError{
+				Line:    1,
+				Column:  1,
+				Token:   "wait",
+				Message: "wait is not supported over WebSocket, send timed scripts over HTTP",
+			},
+		},
+		{
+			Seq:   7,
+			Error: "line 2, column 1: at is not supported over WebSocket, send timed scripts over HTTP",
+			Details: &ParseError{
+				Line:    2,
+				Column:  1,
+				Token:   "at",
+				Message: "at is not supported over WebSocket, send timed scripts over HTTP",
+			},
+		},
+		{Seq: 8, OK: true},
+	}
+	for _, w := range want {
+		var ack Ack
+		assert.NoError(t, websocket.JSON.Receive(ws, &ack))
+		assert.Equal(t, w, ack)
+	}
+}
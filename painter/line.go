@@ -0,0 +1,127 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// defaultStrokeWidth товщина ліній, якщо Width не задано.
+const defaultStrokeWidth = 2
+
+// Line малює відрізок від (X1, Y1) до (X2, Y2) товщиною Width пікселів із заокругленими кінцями.
+type Line struct {
+	X1, Y1, X2, Y2 int
+	C              color.Color
+	Width          int
+}
+
+func (op *Line) Do(t screen.Texture) bool {
+	pl := Polyline{Points: []image.Point{{op.X1, op.Y1}, {op.X2, op.Y2}}, C: op.C, Width: op.Width}
+	return pl.Do(t)
+}
+
+// Polyline малює ламану через точки Points товщиною Width пікселів. Сегменти з'єднуються та закінчуються
+// заокругленнями.
+type Polyline struct {
+	Points []image.Point
+	C      color.Color
+	Width  int
+}
+
+func (op *Polyline) Do(t screen.Texture) bool {
+	c := op.C
+	if c == nil {
+		c = color.Black
+	}
+	w := op.Width
+	if w <= 0 {
+		w = defaultStrokeWidth
+	}
+
+	// Сегменти та заокруглення перекриваються, тож спершу об'єднуємо їх відрізки, щоб напівпрозора лінія
+	// мала однаковий колір по всій довжині. Відрізки за межами текстури відкидаються одразу.
+	b := t.Bounds()
+	var spans []span
+	for i := 1; i < len(op.Points); i++ {
+		spans = append(spans, segmentSpans(op.Points[i-1], op.Points[i], float64(w), b)...)
+	}
+	if w > 2 {
+		for _, p := range op.Points {
+			spans = append(spans, diskSpans(p, w/2, b)...)
+		}
+	}
+	drawSpans(t, mergeSpans(spans), c, drawOp(c))
+	return false
+}
+
+// segmentSpans розбиває прямокутник товщиною w навколо відрізка ab на відрізки рядків пікселів у межах clip.
+func segmentSpans(a, b image.Point, w float64, clip image.Rectangle) []span {
+	ax, ay, bx, by := float64(a.X)+0.5, float64(a.Y)+0.5, float64(b.X)+0.5, float64(b.Y)+0.5
+	dx, dy := bx-ax, by-ay
+	l := math.Hypot(dx, dy)
+	if l == 0 {
+		dx, dy, l = 1, 0, 1
+	}
+	// Нормаль до відрізка довжиною w/2.
+	nx, ny := -dy/l*w/2, dx/l*w/2
+	quad := [4][2]float64{{ax + nx, ay + ny}, {bx + nx, by + ny}, {bx - nx, by - ny}, {ax - nx, ay - ny}}
+	return convexSpans(quad[:], clip)
+}
+
+// convexSpans розбиває опуклий багатокутник pts на відрізки рядків пікселів у межах clip, перевіряючи перетин
+// кожного рядка з його сторонами. Кожен рядок, який перетинає багатокутник, займає щонайменше один піксель.
+func convexSpans(pts [][2]float64, clip image.Rectangle) []span {
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, p := range pts {
+		minY, maxY = math.Min(minY, p[1]), math.Max(maxY, p[1])
+	}
+	minY = math.Max(math.Floor(minY), float64(clip.Min.Y))
+	maxY = math.Min(math.Ceil(maxY), float64(clip.Max.Y-1))
+	var spans []span
+	for y := int(minY); float64(y) <= maxY; y++ {
+		cy := float64(y) + 0.5
+		x1, x2 := math.Inf(1), math.Inf(-1)
+		for i := range pts {
+			p, q := pts[i], pts[(i+1)%len(pts)]
+			if (cy < p[1]) == (cy < q[1]) {
+				if p[1] != cy || q[1] != cy {
+					continue
+				}
+				// Горизонтальна сторона на рівні центру рядка.
+				x1, x2 = math.Min(x1, math.Min(p[0], q[0])), math.Max(x2, math.Max(p[0], q[0]))
+				continue
+			}
+			x := p[0] + (cy-p[1])*(q[0]-p[0])/(q[1]-p[1])
+			x1, x2 = math.Min(x1, x), math.Max(x2, x)
+		}
+		x1, x2 = math.Max(x1, float64(clip.Min.X-1)), math.Min(x2, float64(clip.Max.X+1))
+		if x1 > x2 {
+			continue
+		}
+		// Рядок займає пікселі, центри яких потрапляють у проміжок, але не менше одного пікселя.
+		l, r := int(math.Ceil(x1-0.5)), int(math.Floor(x2-0.5))+1
+		if r <= l {
+			l = int(math.Floor((x1 + x2) / 2))
+			r = l + 1
+		}
+		if l, r = max(l, clip.Min.X), min(r, clip.Max.X); l < r {
+			spans = append(spans, span{y: y, x1: l, x2: r})
+		}
+	}
+	return spans
+}
+
+// diskSpans розбиває круг радіусом r з центром у p на відрізки рядків пікселів у межах clip.
+func diskSpans(p image.Point, r int, clip image.Rectangle) []span {
+	var spans []span
+	for y := max(p.Y-r, clip.Min.Y); y < min(p.Y+r, clip.Max.Y); y++ {
+		hw := ellipseHalfWidth(r, r, float64(y-p.Y)+0.5)
+		if x1, x2 := max(p.X-hw, clip.Min.X), min(p.X+hw, clip.Max.X); hw > 0 && x1 < x2 {
+			spans = append(spans, span{y: y, x1: x1, x2: x2})
+		}
+	}
+	return spans
+}
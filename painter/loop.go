@@ -1,6 +1,8 @@
 package painter
 
 import (
+	"context"
+	"errors"
 	"image"
 	"sync"
 	"time"
@@ -13,148 +15,352 @@ type Receiver interface {
 	Update(t screen.Texture)
 }
 
+// StopMode визначає, що відбувається з операціями, які залишились у черзі під час зупинки циклу подій.
+type StopMode int
+
+const (
+	// StopDrain виконує всі операції, додані в чергу до зупинки, і відправляє останній готовий кадр.
+	StopDrain StopMode = iota
+	// StopImmediate відкидає операції, які залишились у черзі.
+	StopImmediate
+)
+
+// ErrLoopRunning повертається при спробі запустити цикл подій, який вже працює.
+var ErrLoopRunning = errors.New("painter: loop is already running")
+
 // Loop реалізує цикл подій для формування текстури отриманої через виконання операцій отриманих з внутрішньої черги.
 type Loop struct {
 	Receiver Receiver
 
-	next screen.Texture // текстура, яка зараз формується
-	prev screen.Texture // текстура, яка була відправлення останнього разу у Receiver
+	// MaxFPS обмежує кількість кадрів, які відправляються у Receiver за секунду. Якщо кадр стає готовим раніше,
+	// ніж минув інтервал між кадрами, він буде відправлений пізніше разом з усіма змінами, що надійшли за цей час.
+	// Нульове значення вимикає обмеження.
+	MaxFPS int
+
+	// StopMode визначає поведінку циклу, коли скасовано контекст, переданий у Run.
+	StopMode StopMode
+
+	// CanvasSize розмір текстур, які формує цикл подій. Нульове значення означає DefaultCanvasSize.
+	CanvasSize image.Point
+
+	// OnError викликається з горутини циклу подій, якщо операція завершилась панікою. Після цього цикл продовжує
+	// виконувати наступні операції. Якщо обробник не задано, помилка записується в журнал.
+	OnError func(err error)
+
+	next *canvas // текстура, яка зараз формується
+	prev *canvas // текстура, яка була відправлення останнього разу у Receiver
+
+	last snapshot // копія кадру, який був відправлений останнього разу у Receiver
+
+	pending     bool      // текстура next готова, але ще не була відправлена у Receiver
+	lastPublish time.Time // час останнього відправлення текстури у Receiver
 
-	stopReq bool
-	stopped chan struct{}
+	timers   timerHeap // операції, відкладені через Scheduled
+	waiting  []*Future // операції, виконані у текстурі next, які чекають на відправлення кадру
+	tracking *Future   // Future відстежуваної операції, яка зараз виконується
+	lastErr  error     // остання помилка, повідомлена через report
+
+	mu       sync.Mutex // захищає поля нижче
+	running  bool
+	cancel   context.CancelFunc
+	done     chan struct{} // закривається після повної зупинки циклу
+	stopMode *StopMode     // режим зупинки, заданий викликом Stop
 
 	MsgQueue messageQueue
 }
 
-var size = image.Pt(800, 800)
+// DefaultCanvasSize розмір полотна за замовчуванням.
+var DefaultCanvasSize = image.Pt(800, 800)
 
-// Start запускає цикл подій. Цей метод потрібно запустити до того, як викликати на ньому будь-які інші методи.
+// Start запускає цикл подій у окремій горутині. Цей метод потрібно запустити до того, як викликати на ньому
+// будь-які інші методи. Зупинити цикл можна через Stop або StopAndWait.
 func (l *Loop) Start(s screen.Screen) {
-	l.next, _ = s.NewTexture(size)
-	l.prev, _ = s.NewTexture(size)
-	l.MsgQueue = messageQueue{}
-	l.stopped = make(chan struct{})
-	
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := l.begin(s, cancel); err != nil {
+		cancel()
+		return
+	}
+
 	// Запускаємо цикл подій у горутині
-	go l.eventProcess()
+	go l.eventProcess(ctx)
+}
+
+// Run запускає цикл подій і блокується, доки не буде скасовано контекст ctx або викликано Stop. Операції, які
+// залишились у черзі, обробляються відповідно до StopMode.
+func (l *Loop) Run(ctx context.Context, s screen.Screen) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if err := l.begin(s, cancel); err != nil {
+		return err
+	}
+	l.eventProcess(ctx)
+	return nil
+}
+
+// begin готує текстури та стан циклу до запуску.
+func (l *Loop) begin(s screen.Screen, cancel context.CancelFunc) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.running {
+		return ErrLoopRunning
+	}
+
+	size := l.CanvasSize
+	if size.X <= 0 || size.Y <= 0 {
+		size = DefaultCanvasSize
+	}
+	next, err := s.NewTexture(size)
+	if err != nil {
+		return err
+	}
+	prev, err := s.NewTexture(size)
+	if err != nil {
+		next.Release()
+		return err
+	}
+	l.next, l.prev = newCanvas(next, s), newCanvas(prev, s)
+	l.pending = false
+
+	l.running = true
+	l.cancel = cancel
+	l.done = make(chan struct{})
+	l.stopMode = nil
+	l.MsgQueue.open()
+	return nil
+}
+
+// eventProcess обробляє операції з черги повідомлень, доки не буде скасовано ctx.
+func (l *Loop) eventProcess(ctx context.Context) {
+	afterTimer := false
+	for ctx.Err() == nil {
+		op, timer, ok := l.nextOp(afterTimer)
+		if !ok {
+			l.waitForWork(ctx)
+			continue
+		}
+		afterTimer = timer
+		l.do(op)
+	}
+	l.shutdown()
+}
+
+// nextOp повертає наступну операцію: відкладену, час якої настав, або з черги, і повідомляє, чи операція відкладена.
+// Після відкладеної операції спершу перевіряється черга, тож кадри анімацій, які не встигають за часом, не блокують
+// нові операції.
+func (l *Loop) nextOp(afterTimer bool) (op Operation, timer, ok bool) {
+	if !afterTimer {
+		if op, ok := l.timers.due(time.Now()); ok {
+			return op, true, true
+		}
+	}
+	if op, ok := l.MsgQueue.tryPull(); ok {
+		return op, false, true
+	}
+	op, ok = l.timers.due(time.Now())
+	return op, ok, ok
+}
+
+// do виконує операцію і відправляє кадр у Receiver, якщо він готовий і це дозволяє MaxFPS.
+func (l *Loop) do(op Operation) {
+	if update := l.apply(op); update {
+		l.pending = true
+	}
+	if l.pending && l.frameDelay() <= 0 {
+		l.publish()
+	}
 }
 
-// eventProcess обробляє операції з черги повідомлень
-func (l *Loop) eventProcess() {
-	for {
-		if op := l.MsgQueue.Pull(); op != nil {
-			if update := op.Do(l.next); update {
-				l.Receiver.Update(l.next)
-				l.next, l.prev = l.prev, l.next
+// apply виконує операцію над текстурою next. Операції Scheduled, час яких ще не настав, потрапляють у чергу таймерів.
+func (l *Loop) apply(op Operation) bool {
+	switch op := op.(type) {
+	case OperationList:
+		ready := false
+		for _, o := range op {
+			ready = l.apply(o) || ready
+		}
+		return ready
+	case *Scheduled:
+		if op.At.After(time.Now()) {
+			if f := l.tracking; f != nil {
+				// Відкладена частина відстежуваної операції завершує її Future лише після виконання.
+				f.parts++
+				l.timers.schedule(op.At, &tracked{op: op.Op, future: f})
+			} else {
+				l.timers.schedule(op.At, op.Op)
 			}
+			return false
 		}
-		
-		if l.stopReq {
-			// Clean up resources before stopping
-			close(l.stopped)
-			return
+		return l.apply(op.Op)
+	case *tracked:
+		prev := l.tracking
+		l.tracking, l.lastErr = op.future, nil
+		ready := l.apply(op.op)
+		l.tracking = prev
+		l.settle(op.future, ready)
+		return ready
+	case *animationFrame:
+		// Кадр анімації планує наступний лише після виконання, тож черга таймерів тримає один кадр анімації.
+		ready := l.exec(op)
+		if next := op.next(time.Now()); next != nil {
+			l.apply(next)
 		}
+		return ready
 	}
+	return l.exec(op)
 }
 
-// Post додає нову операцію у внутрішню чергу.
-func (l *Loop) Post(op Operation) {
-	if op != nil {
-		l.MsgQueue.Push(op)
+// shutdown завершує роботу циклу відповідно до режиму зупинки і звільняє текстури.
+func (l *Loop) shutdown() {
+	l.mu.Lock()
+	mode := l.StopMode
+	if l.stopMode != nil {
+		mode = *l.stopMode
+	}
+	l.mu.Unlock()
+
+	// Після закриття черги нові операції не приймаються, тож вона гарантовано спорожніє.
+	l.MsgQueue.close()
+	if mode == StopDrain {
+		for op, ok := l.MsgQueue.tryPull(); ok; op, ok = l.MsgQueue.tryPull() {
+			l.do(op)
+		}
+		if l.pending {
+			l.publish()
+		}
+	} else {
+		l.MsgQueue.Clear()
+	}
+	l.timers.clear(ErrLoopStopped)
+	for _, f := range l.waiting {
+		f.resolve(false, ErrLoopStopped)
 	}
+	l.waiting = nil
+
+	l.next.Release()
+	l.prev.Release()
+
+	l.mu.Lock()
+	l.running = false
+	l.cancel = nil
+	close(l.done)
+	l.mu.Unlock()
 }
 
-// PostWithTimeout adds an operation with a timeout and returns whether the operation was accepted
-func (l *Loop) PostWithTimeout(op Operation, timeout time.Duration) bool {
-	if op == nil {
-		return false
+// waitForWork блокується до появи нових операцій у черзі, до моменту, коли потрібно відправити відкладений кадр
+// чи виконати відкладену операцію, або до скасування ctx.
+func (l *Loop) waitForWork(ctx context.Context) {
+	wait := time.Duration(-1)
+	if l.pending {
+		wait = l.frameDelay()
+	}
+	if at, ok := l.timers.next(); ok {
+		if d := time.Until(at); wait < 0 || d < wait {
+			wait = d
+		}
+	}
+
+	var timeout <-chan time.Time
+	if wait >= 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
 	}
-	
-	// Use a channel to signal when the operation is pushed
-	done := make(chan struct{})
-	
-	go func() {
-		l.MsgQueue.Push(op)
-		close(done)
-	}()
-	
-	// Wait for the operation to be pushed or timeout
 	select {
-	case <-done:
-		return true
-	case <-time.After(timeout):
-		return false
+	case <-l.MsgQueue.ready():
+	case <-timeout:
+		if l.pending && l.frameDelay() <= 0 {
+			l.publish()
+		}
+	case <-ctx.Done():
 	}
 }
 
-// StopAndWait сигналізує про необхідність завершити цикл та блокується до моменту його повної зупинки.
-func (l *Loop) StopAndWait() {
-	l.Post(OperationFunc(func(screen.Texture) {
-		l.stopReq = true
-	}))
-	<-l.stopped
-	
-	// Clean up textures
-	if l.next != nil {
-		l.next.Release()
-		l.next = nil
-	}
-	
-	if l.prev != nil {
-		l.prev.Release()
-		l.prev = nil
+// frameDelay повертає час, який залишився до моменту, коли можна відправити наступний кадр.
+func (l *Loop) frameDelay() time.Duration {
+	if l.MaxFPS <= 0 {
+		return 0
 	}
+	return time.Until(l.lastPublish.Add(time.Second / time.Duration(l.MaxFPS)))
 }
 
-func (l *Loop) Size() int {
-	return l.MsgQueue.Size()
+// publish відправляє готову текстуру у Receiver і міняє текстури місцями.
+func (l *Loop) publish() {
+	l.Receiver.Update(l.next.Texture)
+	l.last.store(l.next.img)
+	l.next, l.prev = l.prev, l.next
+	l.pending = false
+	l.lastPublish = time.Now()
+	for _, f := range l.waiting {
+		f.resolve(true, nil)
+	}
+	l.waiting = nil
 }
 
-// messageQueue реалізує чергу повідомлень з блокуванням
-type messageQueue struct {
-	Queue   []Operation
-	mu      sync.Mutex
-	blocked chan struct{}
+// Post додає нову операцію у внутрішню чергу. Якщо черга заповнена, поведінка визначається MsgQueue.Policy:
+// Post може заблокуватись або повернути ErrQueueFull. Після зупинки циклу повертається ErrLoopStopped.
+func (l *Loop) Post(op Operation) error {
+	return l.PostContext(context.Background(), op)
 }
 
-// Push додає операцію в чергу
-func (MsgQueue *messageQueue) Push(op Operation) {
-	MsgQueue.mu.Lock()
-	defer MsgQueue.mu.Unlock()
+// PostContext працює як Post, але припиняє очікування місця в черзі після скасування ctx.
+func (l *Loop) PostContext(ctx context.Context, op Operation) error {
+	return l.PostPriority(ctx, op, PriorityNormal)
+}
 
-	MsgQueue.Queue = append(MsgQueue.Queue, op)
-	if MsgQueue.blocked != nil {
-		close(MsgQueue.blocked)
-		MsgQueue.blocked = nil
+// PostPriority додає операцію у смугу черги з пріоритетом prio. Операції з вищим пріоритетом виконуються раніше
+// за всі операції з нижчим пріоритетом, які ще очікують у черзі.
+func (l *Loop) PostPriority(ctx context.Context, op Operation, prio Priority) error {
+	if op == nil {
+		return nil
 	}
+	return l.MsgQueue.push(ctx, op, prio)
 }
 
-// Pull витягає наступну операцію з черги (блокуюча операція)
-func (MsgQueue *messageQueue) Pull() Operation {
-	MsgQueue.mu.Lock()
-	defer MsgQueue.mu.Unlock()
+// PostWithTimeout adds an operation with a timeout and returns whether the operation was accepted
+func (l *Loop) PostWithTimeout(op Operation, timeout time.Duration) bool {
+	if op == nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return l.PostContext(ctx, op) == nil
+}
 
-	for len(MsgQueue.Queue) == 0 {
-		MsgQueue.blocked = make(chan struct{})
-		MsgQueue.mu.Unlock()
-		<-MsgQueue.blocked
-		MsgQueue.mu.Lock()
+// Stop сигналізує про необхідність завершити цикл у режимі mode і не чекає на його зупинку.
+func (l *Loop) Stop(mode StopMode) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.running {
+		return
 	}
+	if l.stopMode == nil {
+		l.stopMode = &mode
+	}
+	l.cancel()
+}
 
-	op := MsgQueue.Queue[0]
-	MsgQueue.Queue[0] = nil
-	MsgQueue.Queue = MsgQueue.Queue[1:]
-	return op
+// Done повертає канал, який закривається після повної зупинки циклу, або nil, якщо цикл ще не запускався.
+func (l *Loop) Done() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.done
+}
+
+// StopAndWait сигналізує про необхідність завершити цикл та блокується до моменту його повної зупинки.
+// Усі операції, додані до виклику, буде виконано.
+func (l *Loop) StopAndWait() {
+	l.Stop(StopDrain)
+	if done := l.Done(); done != nil {
+		<-done
+	}
 }
 
-func (MsgQueue *messageQueue) Size() int {
-	MsgQueue.mu.Lock()
-	defer MsgQueue.mu.Unlock()
-	return len(MsgQueue.Queue)
+// Snapshot повертає копію кадру, який був відправлений у Receiver останнім, або nil, якщо кадрів ще не було.
+func (l *Loop) Snapshot() *image.RGBA {
+	return l.last.load()
 }
 
-func (MsgQueue *messageQueue) Clear() {
-	MsgQueue.mu.Lock()
-	defer MsgQueue.mu.Unlock()
-	MsgQueue.Queue = nil
-}
\ No newline at end of file
+func (l *Loop) Size() int {
+	return l.MsgQueue.Size()
+}
@@ -1,10 +1,13 @@
 package painter
 
 import (
+	"context"
 	"image"
 	"image/color"
 	"image/draw"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -28,7 +31,7 @@ type mockTexture struct {
 
 func (m *mockTexture) Release() {}
 
-func (m *mockTexture) Size() image.Point { return size }
+func (m *mockTexture) Size() image.Point { return DefaultCanvasSize }
 
 func (m *mockTexture) Bounds() image.Rectangle {
 	return image.Rectangle{Max: m.Size()}
@@ -130,17 +133,17 @@ func TestMessageQueue(t *testing.T) {
 	mq := messageQueue{}
 	
 	// Перевірка порожньої черги
-	assert.Equal(t, 0, len(mq.Queue))
+	assert.Equal(t, 0, mq.Size())
 	
 	// Додавання операцій
 	op1 := OperationFunc(WhiteFill)
 	op2 := OperationFunc(GreenFill)
 	
 	mq.Push(op1)
-	assert.Equal(t, 1, len(mq.Queue))
+	assert.Equal(t, 1, mq.Size())
 	
 	mq.Push(op2)
-	assert.Equal(t, 2, len(mq.Queue))
+	assert.Equal(t, 2, mq.Size())
 	
 	// Витягування операцій
 	pulledOp1 := mq.Pull()
@@ -150,11 +153,312 @@ func TestMessageQueue(t *testing.T) {
 	assert.NotNil(t, pulledOp2)
 	
 	// Після витягування всіх операцій черга порожня
-	assert.Equal(t, 0, len(mq.Queue))
+	assert.Equal(t, 0, mq.Size())
 	
 	// Перевіряємо, що операції витягнуті в правильному порядку
 	_, ok1 := pulledOp1.(OperationFunc)
 	_, ok2 := pulledOp2.(OperationFunc)
 	assert.True(t, ok1)
 	assert.True(t, ok2)
-}
\ No newline at end of file
+}
+func TestLoop_Snapshot(t *testing.T) {
+	var (
+		l  Loop
+		tr testReceiver
+	)
+	l.Receiver = &tr
+
+	l.Start(mockScreen{})
+	assert.Nil(t, l.Snapshot())
+
+	done := make(chan struct{})
+	l.Post(OperationFunc(GreenFill))
+	l.Post(&BgRectangle{X1: 0, Y1: 0, X2: 10, Y2: 10})
+	l.Post(UpdateOp)
+	l.Post(OperationFunc(WhiteFill))
+	l.Post(OperationFunc(func(screen.Texture) { close(done) }))
+	<-done
+
+	img := l.Snapshot()
+	if assert.NotNil(t, img) {
+		assert.Equal(t, DefaultCanvasSize, img.Bounds().Size())
+		assert.Equal(t, color.RGBA{A: 0xff}, img.RGBAAt(5, 5))
+		assert.Equal(t, color.RGBA{G: 0xff, A: 0xff}, img.RGBAAt(50, 50))
+	}
+
+	l.StopAndWait()
+}
+
+// countingReceiver рахує кількість отриманих кадрів
+type countingReceiver struct {
+	updates atomic.Int32
+}
+
+func (cr *countingReceiver) Update(t screen.Texture) {
+	cr.updates.Add(1)
+}
+
+func TestLoop_MaxFPS(t *testing.T) {
+	var (
+		l  Loop
+		cr countingReceiver
+	)
+	l.Receiver = &cr
+	l.MaxFPS = 5
+
+	l.Start(mockScreen{})
+	for i := 0; i < 10; i++ {
+		l.Post(UpdateOp)
+	}
+
+	// Перший кадр відправляється одразу, решта об'єднуються в один відкладений кадр.
+	assert.Eventually(t, func() bool { return cr.updates.Load() == 2 }, time.Second, 10*time.Millisecond)
+	time.Sleep(300 * time.Millisecond)
+	assert.Equal(t, int32(2), cr.updates.Load())
+
+	l.StopAndWait()
+}
+
+func TestLoop_Run_StopModes(t *testing.T) {
+	for _, mode := range []StopMode{StopDrain, StopImmediate} {
+		var (
+			l  Loop
+			cr countingReceiver
+		)
+		l.Receiver = &cr
+		l.StopMode = mode
+
+		ctx, cancel := context.WithCancel(context.Background())
+		result := make(chan error)
+		go func() { result <- l.Run(ctx, mockScreen{}) }()
+
+		// Блокуємо цикл, щоб наступні операції залишились у черзі під час зупинки.
+		started, release := make(chan struct{}), make(chan struct{})
+		l.Post(OperationFunc(func(screen.Texture) {
+			close(started)
+			<-release
+		}))
+		<-started
+
+		var executed atomic.Int32
+		for i := 0; i < 3; i++ {
+			l.Post(OperationFunc(func(screen.Texture) { executed.Add(1) }))
+		}
+		l.Post(UpdateOp)
+		assert.ErrorIs(t, l.Run(context.Background(), mockScreen{}), ErrLoopRunning)
+
+		cancel()
+		close(release)
+		assert.NoError(t, <-result)
+
+		if mode == StopDrain {
+			assert.Equal(t, int32(3), executed.Load())
+			assert.Equal(t, int32(1), cr.updates.Load())
+		} else {
+			assert.Equal(t, int32(0), executed.Load())
+			assert.Equal(t, int32(0), cr.updates.Load())
+		}
+
+		// Після зупинки операції не приймаються.
+		l.Post(UpdateOp)
+		assert.Equal(t, 0, l.Size())
+		l.StopAndWait()
+	}
+}
+
+func TestMessageQueue_Overflow(t *testing.T) {
+	op := OperationFunc(WhiteFill)
+
+	mq := messageQueue{Capacity: 2, Policy: OverflowReject}
+	assert.NoError(t, mq.Push(op))
+	assert.NoError(t, mq.Push(UpdateOp))
+	assert.ErrorIs(t, mq.Push(op), ErrQueueFull)
+	assert.Equal(t, 2, mq.Size())
+
+	mq = messageQueue{Capacity: 2, Policy: OverflowDropOldest}
+	assert.NoError(t, mq.Push(op))
+	assert.NoError(t, mq.Push(UpdateOp))
+	assert.NoError(t, mq.Push(OperationList{UpdateOp}))
+	assert.Equal(t, UpdateOp, mq.Pull())
+	assert.Equal(t, OperationList{UpdateOp}, mq.Pull())
+
+	mq = messageQueue{Capacity: 2, Policy: OverflowDropNonUpdate}
+	assert.NoError(t, mq.Push(UpdateOp))
+	assert.NoError(t, mq.Push(op))
+	assert.NoError(t, mq.Push(OperationList{op, UpdateOp}))
+	assert.ErrorIs(t, mq.Push(op), ErrQueueFull)
+	assert.Equal(t, UpdateOp, mq.Pull())
+	assert.Equal(t, 1, mq.Size())
+
+	mq = messageQueue{Capacity: 1, Policy: OverflowBlock}
+	assert.NoError(t, mq.Push(op))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, mq.push(ctx, op, PriorityNormal), context.DeadlineExceeded)
+
+	pushed := make(chan error)
+	go func() { pushed <- mq.Push(UpdateOp) }()
+	mq.Pull()
+	assert.NoError(t, <-pushed)
+	assert.Equal(t, UpdateOp, mq.Pull())
+
+	mq.close()
+	assert.ErrorIs(t, mq.Push(op), ErrLoopStopped)
+}
+
+func TestMessageQueue_Priority(t *testing.T) {
+	low, normal1, normal2, high := &testOp{id: 1}, &testOp{id: 2}, &testOp{id: 3}, &testOp{id: 4}
+
+	mq := messageQueue{}
+	assert.NoError(t, mq.push(context.Background(), low, PriorityLow))
+	assert.NoError(t, mq.push(context.Background(), normal1, PriorityNormal))
+	assert.NoError(t, mq.push(context.Background(), normal2, PriorityNormal))
+	assert.NoError(t, mq.push(context.Background(), high, PriorityHigh))
+	assert.Error(t, mq.push(context.Background(), low, Priority(5)))
+	assert.Equal(t, 4, mq.Size())
+
+	for _, want := range []Operation{high, normal1, normal2, low} {
+		assert.Same(t, want, mq.Pull())
+	}
+
+	// Під час переповнення спершу відкидаються операції з нижчим пріоритетом.
+	mq = messageQueue{Capacity: 2, Policy: OverflowDropOldest}
+	assert.NoError(t, mq.push(context.Background(), high, PriorityHigh))
+	assert.NoError(t, mq.push(context.Background(), low, PriorityLow))
+	assert.NoError(t, mq.push(context.Background(), normal1, PriorityNormal))
+	assert.Same(t, high, mq.Pull())
+	assert.Same(t, normal1, mq.Pull())
+
+	// Операція з нижчим пріоритетом не витісняє операції з вищим.
+	for _, policy := range []OverflowPolicy{OverflowDropOldest, OverflowDropNonUpdate} {
+		mq = messageQueue{Capacity: 2, Policy: policy}
+		assert.NoError(t, mq.push(context.Background(), high, PriorityHigh))
+		assert.NoError(t, mq.push(context.Background(), normal1, PriorityHigh))
+		assert.ErrorIs(t, mq.push(context.Background(), low, PriorityLow), ErrQueueFull)
+		assert.NoError(t, mq.push(context.Background(), normal2, PriorityHigh))
+		assert.Same(t, normal1, mq.Pull())
+		assert.Same(t, normal2, mq.Pull())
+	}
+
+	var p Priority
+	assert.NoError(t, p.UnmarshalText([]byte("high")))
+	assert.Equal(t, PriorityHigh, p)
+	assert.Equal(t, "low", PriorityLow.String())
+	assert.Error(t, p.UnmarshalText([]byte("urgent")))
+}
+
+type testOp struct{ id int }
+
+func (op *testOp) Do(screen.Texture) bool { return false }
+
+func TestLoop_PostAt(t *testing.T) {
+	var (
+		l  Loop
+		mu sync.Mutex
+		tr []string
+	)
+	record := func(name string) Operation {
+		return OperationFunc(func(screen.Texture) {
+			mu.Lock()
+			defer mu.Unlock()
+			tr = append(tr, name)
+		})
+	}
+	l.Receiver = &testReceiver{}
+
+	l.Start(mockScreen{})
+	start := time.Now()
+	at := start.Add(60 * time.Millisecond)
+	l.PostAt(record("second"), at)
+	l.PostAt(record("third"), at)
+	l.PostAfter(record("first"), 20*time.Millisecond)
+	l.Post(OperationList{record("now"), &Scheduled{At: start.Add(100 * time.Millisecond), Op: record("last")}})
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(tr) == 5
+	}, time.Second, 5*time.Millisecond)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.Equal(t, []string{"now", "first", "second", "third", "last"}, tr)
+
+	// Відкладені операції, час яких не настав, відкидаються під час зупинки.
+	l.PostAfter(record("dropped"), time.Hour)
+	l.StopAndWait()
+	assert.Len(t, tr, 5)
+}
+
+func TestLoop_Submit(t *testing.T) {
+	var (
+		l  Loop
+		cr countingReceiver
+	)
+	l.Receiver = &cr
+	l.MaxFPS = 10
+
+	l.Start(mockScreen{})
+	first, err := l.Submit(context.Background(), UpdateOp, PriorityNormal)
+	assert.NoError(t, err)
+	assert.NoError(t, first.Wait(context.Background()))
+	assert.True(t, first.Published())
+
+	// Наступний кадр відкладається через MaxFPS, тож Future завершується лише після його відправлення.
+	second, _ := l.Submit(context.Background(), UpdateOp, PriorityNormal)
+	noop, _ := l.Submit(context.Background(), OperationFunc(WhiteFill), PriorityNormal)
+	assert.NoError(t, noop.Wait(context.Background()))
+	assert.False(t, noop.Published())
+	assert.NoError(t, second.Wait(context.Background()))
+	assert.True(t, second.Published())
+	assert.Equal(t, int32(2), cr.updates.Load())
+
+	// Future завершується лише після виконання частин операції, відкладених через Scheduled.
+	start := time.Now()
+	delayed, _ := l.Submit(context.Background(), OperationList{
+		UpdateOp,
+		&Scheduled{At: start.Add(50 * time.Millisecond), Op: OperationFunc(WhiteFill)},
+		&Scheduled{At: start.Add(100 * time.Millisecond), Op: UpdateOp},
+	}, PriorityNormal)
+	assert.NoError(t, delayed.Wait(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	assert.True(t, delayed.Published())
+
+	pending, _ := l.Submit(context.Background(), &Scheduled{At: time.Now().Add(time.Hour), Op: UpdateOp}, PriorityNormal)
+	l.StopAndWait()
+	assert.ErrorIs(t, pending.Err(), ErrLoopStopped)
+
+	// Операції, відкинуті з черги, завершуються з помилкою.
+	var q Loop
+	q.MsgQueue.Capacity = 1
+	q.MsgQueue.Policy = OverflowDropOldest
+	old, _ := q.Submit(context.Background(), UpdateOp, PriorityNormal)
+	q.Post(UpdateOp)
+	assert.ErrorIs(t, old.Wait(context.Background()), ErrOperationDropped)
+}
+
+func TestLoop_PanicIsolation(t *testing.T) {
+	var (
+		l    Loop
+		cr   countingReceiver
+		errs = make(chan error, 2)
+	)
+	l.Receiver = &cr
+	l.OnError = func(err error) { errs <- err }
+	boom := OperationFunc(func(screen.Texture) { panic("boom") })
+
+	l.Start(mockScreen{})
+	l.Post(OperationList{boom, UpdateOp})
+	f, err := l.Submit(context.Background(), boom, PriorityNormal)
+	assert.NoError(t, err)
+	l.Post(UpdateOp)
+	l.StopAndWait()
+
+	for i := 0; i < 2; i++ {
+		var pe *PanicError
+		assert.ErrorAs(t, <-errs, &pe)
+		assert.Equal(t, "boom", pe.Value)
+		assert.NotEmpty(t, pe.Stack)
+	}
+	assert.ErrorAs(t, f.Err(), new(*PanicError))
+	// Операції після паніки виконуються далі.
+	assert.Equal(t, int32(2), cr.updates.Load())
+}
@@ -3,6 +3,7 @@ package painter
 import (
 	"image"
 	"image/color"
+	"math"
 
 	"golang.org/x/exp/shiny/screen"
 	"golang.org/x/image/draw"
@@ -49,32 +50,78 @@ func GreenFill(t screen.Texture) {
 	t.Fill(t.Bounds(), color.RGBA{G: 0xff, A: 0xff}, draw.Src)
 }
 
-// BgRectangle малює чорний прямокутник на фоні.
+// ColorFill зафарбовує текстуру у довільний колір.
+type ColorFill struct {
+	C color.Color
+}
+
+func (op *ColorFill) Do(t screen.Texture) bool {
+	t.Fill(t.Bounds(), op.C, draw.Src)
+	return false
+}
+
+// BgRectangle малює прямокутник на фоні кольором C (чорним, якщо колір не задано) або зображенням Fill,
+// наприклад градієнтом, якщо воно задане.
 type BgRectangle struct {
 	X1, Y1, X2, Y2 int
+	C              color.Color
+	Fill           image.Image
 }
 
 func (op *BgRectangle) Do(t screen.Texture) bool {
-	t.Fill(image.Rect(op.X1, op.Y1, op.X2, op.Y2), color.Black, draw.Src)
+	r := image.Rect(op.X1, op.Y1, op.X2, op.Y2)
+	if op.Fill != nil {
+		drawPattern(t, r, op.Fill, draw.Over)
+		return false
+	}
+	c := op.C
+	if c == nil {
+		c = color.Black
+	}
+	t.Fill(r, c, drawOp(c))
 	return false
 }
 
-// Figure малює фігуру з центром у координатах (x, y).
+// Figure малює фігуру з центром у координатах (x, y). ID дозволяє звертатися до фігури за іменем.
+// Size задає ширину фігури у пікселях, решта розмірів пропорційні їй. Якщо Size не задано, фігура має ширину
+// DefaultFigureSize на полотні DefaultCanvasSize і масштабується за меншою стороною текстури.
 type Figure struct {
+	ID   string
 	X, Y int
+	Size int
 	C    color.RGBA
 }
 
+// DefaultFigureSize ширина фігури у пікселях на полотні DefaultCanvasSize.
+const DefaultFigureSize = 300
+
 func (op *Figure) Do(t screen.Texture) bool {
-	// Малюємо фігуру у вигляді перевернутої літери "Т"
+	var k float64
+	if op.Size > 0 {
+		k = float64(op.Size) / DefaultFigureSize
+	} else {
+		sz := t.Size()
+		k = float64(min(sz.X, sz.Y)) / float64(min(DefaultCanvasSize.X, DefaultCanvasSize.Y))
+	}
+	// Пропорції фігури задані для ширини DefaultFigureSize.
+	w1, w2, h := scaleInt(DefaultFigureSize/2, k), scaleInt(60, k), scaleInt(140, k)
+
+	// Малюємо фігуру у вигляді перевернутої літери "Т". Частини не перекриваються, щоб напівпрозора фігура
+	// не ставала темнішою у місці їх з'єднання.
+	dop := drawOp(op.C)
 	// Горизонтальна частина
-	t.Fill(image.Rect(op.X-150, op.Y, op.X+150, op.Y-140), op.C, draw.Src)
+	t.Fill(image.Rect(op.X-w1, op.Y, op.X+w1, op.Y-h), op.C, dop)
 	// Вертикальна частина
-	t.Fill(image.Rect(op.X-60, op.Y-140, op.X+60, op.Y+140), op.C, draw.Src)
+	t.Fill(image.Rect(op.X-w2, op.Y, op.X+w2, op.Y+h), op.C, dop)
 	return false
 }
 
-// Move переміщує всі фігури.
+// scaleInt масштабує розмір v у k разів з округленням.
+func scaleInt(v int, k float64) int {
+	return int(math.Round(float64(v) * k))
+}
+
+// Move переміщує всі фігури зі списку Figures.
 type Move struct {
 	X, Y    int
 	Figures []*Figure
@@ -88,7 +135,72 @@ func (op *Move) Do(t screen.Texture) bool {
 	return false
 }
 
+// drawOp повертає draw.Src для непрозорого кольору і draw.Over для напівпрозорого, щоб напівпрозорі фігури
+// змішувались з уже намальованим вмістом текстури.
+func drawOp(c color.Color) draw.Op {
+	if _, _, _, a := c.RGBA(); a == 0xffff {
+		return draw.Src
+	}
+	return draw.Over
+}
+
 // ResetScreen очищає поточний стан текстури і заповнює її чорним кольором.
 func ResetScreen(t screen.Texture) {
 	t.Fill(t.Bounds(), color.Black, draw.Src)
 }
+
+// Ellipse малює еліпс з центром у координатах (X, Y) та радіусами RX і RY. Якщо Outline встановлено,
+// малюється лише контур товщиною Width пікселів, інакше еліпс зафарбовується повністю.
+type Ellipse struct {
+	X, Y, RX, RY int
+	C            color.Color
+	Outline      bool
+	Width        int
+}
+
+// defaultOutlineWidth товщина контуру еліпса, якщо Width не задано.
+const defaultOutlineWidth = 2
+
+func (op *Ellipse) Do(t screen.Texture) bool {
+	if op.RX <= 0 || op.RY <= 0 {
+		return false
+	}
+	c := op.C
+	if c == nil {
+		c = color.Black
+	}
+	w := op.Width
+	if w <= 0 {
+		w = defaultOutlineWidth
+	}
+	irx, iry := op.RX-w, op.RY-w
+	dop := drawOp(c)
+
+	// Заповнюємо еліпс горизонтальними смугами висотою в один піксель, пропускаючи рядки за межами текстури.
+	b := t.Bounds()
+	for y := max(op.Y-op.RY, b.Min.Y); y < min(op.Y+op.RY, b.Max.Y); y++ {
+		outer := ellipseHalfWidth(op.RX, op.RY, float64(y-op.Y)+0.5)
+		x1, x2 := op.X-outer, op.X+outer
+		if !op.Outline || irx <= 0 || iry <= 0 {
+			t.Fill(image.Rect(x1, y, x2, y+1), c, dop)
+			continue
+		}
+		inner := ellipseHalfWidth(irx, iry, float64(y-op.Y)+0.5)
+		if inner == 0 {
+			t.Fill(image.Rect(x1, y, x2, y+1), c, dop)
+			continue
+		}
+		t.Fill(image.Rect(x1, y, op.X-inner, y+1), c, dop)
+		t.Fill(image.Rect(op.X+inner, y, x2, y+1), c, dop)
+	}
+	return false
+}
+
+// ellipseHalfWidth повертає половину ширини еліпса з радіусами rx, ry на відстані dy від центру.
+func ellipseHalfWidth(rx, ry int, dy float64) int {
+	k := dy / float64(ry)
+	if k <= -1 || k >= 1 {
+		return 0
+	}
+	return int(math.Round(float64(rx) * math.Sqrt(1-k*k)))
+}
@@ -0,0 +1,394 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/sifes/kpi-3-lab3/ui/headless"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/exp/shiny/screen"
+)
+
+// newTestTexture створює текстуру в пам'яті, пікселі якої можна перевірити.
+func newTestTexture(t *testing.T) *headless.Texture {
+	tx, err := headless.Screen{}.NewTexture(image.Pt(100, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx.(*headless.Texture)
+}
+
+func TestEllipse_Do(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	black := color.RGBA{A: 0xff}
+
+	tx := newTestTexture(t)
+	ResetScreen(tx)
+	(&Ellipse{X: 50, Y: 50, RX: 40, RY: 20, C: red}).Do(tx)
+	img := tx.Image()
+	assert.Equal(t, red, img.RGBAAt(50, 50))
+	assert.Equal(t, red, img.RGBAAt(15, 50))
+	assert.Equal(t, black, img.RGBAAt(50, 25))
+	assert.Equal(t, black, img.RGBAAt(12, 35))
+
+	tx = newTestTexture(t)
+	ResetScreen(tx)
+	(&Ellipse{X: 50, Y: 50, RX: 30, RY: 30, C: red, Outline: true, Width: 3}).Do(tx)
+	img = tx.Image()
+	assert.Equal(t, black, img.RGBAAt(50, 50))
+	assert.Equal(t, red, img.RGBAAt(21, 50))
+	assert.Equal(t, red, img.RGBAAt(50, 78))
+	assert.Equal(t, black, img.RGBAAt(50, 90))
+}
+
+// fillCounter рахує виклики Fill текстури.
+type fillCounter struct {
+	screen.Texture
+	fills int
+}
+
+func (c *fillCounter) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	c.fills++
+	c.Texture.Fill(dr, src, op)
+}
+
+func TestEllipse_Do_Clip(t *testing.T) {
+	tx := newTestTexture(t)
+	c := &fillCounter{Texture: tx}
+	(&Ellipse{X: 50, Y: 50, RX: 5e6, RY: 5e6, C: color.White}).Do(c)
+	assert.Equal(t, 100, c.fills)
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, tx.Image().RGBAAt(0, 99))
+}
+
+// bufferCounter рахує буфери, створені екраном.
+type bufferCounter struct {
+	headless.Screen
+	buffers int
+}
+
+func (s *bufferCounter) NewBuffer(size image.Point) (screen.Buffer, error) {
+	s.buffers++
+	return s.Screen.NewBuffer(size)
+}
+
+func TestCanvas_Buffer(t *testing.T) {
+	tx := newTestTexture(t)
+	s := &bufferCounter{}
+	cv := newCanvas(tx, s)
+	red := color.RGBA{R: 0xff, A: 0xff}
+
+	// Усі операції малюють через один буфер розміром з текстуру.
+	(&Polygon{Points: []image.Point{{10, 10}, {30, 10}, {30, 30}}, C: red}).Do(cv)
+	(&Line{X1: 50, Y1: 50, X2: 90, Y2: 50, C: red, Width: 4}).Do(cv)
+	(&Text{X: 0, Y: 60, Size: 20, S: "W", C: red}).Do(cv)
+	assert.Equal(t, 1, s.buffers)
+	assert.Equal(t, red, tx.Image().RGBAAt(25, 12))
+	assert.Equal(t, red, tx.Image().RGBAAt(70, 50))
+	assert.Equal(t, color.RGBA{}, tx.Image().RGBAAt(12, 25))
+	assert.Equal(t, tx.Image().Pix, cv.pixels().Pix)
+	cv.Release()
+}
+
+func TestAnimation_Frames(t *testing.T) {
+	start := time.Now()
+	for _, ease := range []Easing{Linear, EaseIn, EaseOut, EaseInOut, Bounce} {
+		assert.InDelta(t, 0, ease(0), 1e-9)
+		assert.InDelta(t, 1, ease(1), 1e-9)
+	}
+
+	var stage Stage
+	a := &Animation{X: 101, Y: -50, ID: "car", Duration: 100 * time.Millisecond, Easing: Bounce, FPS: 50}
+	begin, first := a.Frames(&stage, start)
+	assert.Equal(t, start.Add(20*time.Millisecond), first.At)
+
+	// Фігура сцени вже у кінцевому положенні, а початок анімації повертає її у початкове.
+	tex := newTestTexture(t)
+	fig := &Figure{ID: "car", X: 201, Y: 50}
+	assert.False(t, begin.Do(tex))
+	assert.False(t, stage.Enter(fig).Do(tex))
+	assert.Equal(t, 100, fig.X)
+	assert.Equal(t, 100, fig.Y)
+
+	// Кожен кадр планує наступний, тож анімація займає в черзі одну операцію.
+	n, last := 0, first
+	for op := first; op != nil; n++ {
+		if n == 2 {
+			// Сцена, надіслана під час анімації, заміняє попередню, а її фігура отримує поточне зміщення.
+			next := &Figure{ID: "car", X: 201, Y: 50}
+			stage.Enter(next).Do(tex)
+			assert.Equal(t, *fig, *next)
+			fig = next
+		}
+		frame := op.Op.(*animationFrame)
+		assert.True(t, frame.Do(tex))
+		last, op = op, frame.next(op.At)
+	}
+	assert.Equal(t, 5, n)
+	assert.Equal(t, start.Add(100*time.Millisecond), last.At)
+	assert.Equal(t, 201, fig.X)
+	assert.Equal(t, 50, fig.Y)
+	assert.Empty(t, stage.offsets)
+
+	// Кадри, час яких вже минув, пропускаються, а наступний кадр зміщує фігуру на пропущені зміщення.
+	skipped := first.Op.(*animationFrame).next(start.Add(70 * time.Millisecond))
+	assert.Equal(t, start.Add(80*time.Millisecond), skipped.At)
+	assert.Equal(t, 1, skipped.Op.(*animationFrame).prev)
+}
+
+func TestFigure_Do_Scale(t *testing.T) {
+	tex := newTestTexture(t)
+	fig := &Figure{X: 50, Y: 50, C: color.RGBA{R: 255, A: 255}}
+	fig.Do(tex)
+
+	// На полотні 100x100 фігура у вісім разів менша, ніж на полотні DefaultCanvasSize.
+	img := tex.Image()
+	assert.Equal(t, fig.C, img.RGBAAt(50-19, 49))
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(50-20, 49))
+	assert.Equal(t, fig.C, img.RGBAAt(50, 50+17))
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(50, 50+18))
+}
+
+func TestFigure_Do_Size(t *testing.T) {
+	tex := newTestTexture(t)
+	fig := &Figure{X: 50, Y: 50, Size: 60, C: color.RGBA{G: 255, A: 255}}
+	fig.Do(tex)
+
+	img := tex.Image()
+	assert.Equal(t, fig.C, img.RGBAAt(50-30, 49))
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(50-31, 49))
+	assert.Equal(t, fig.C, img.RGBAAt(50+11, 50+27))
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(50+12, 50+27))
+	assert.Equal(t, color.RGBA{}, img.RGBAAt(50, 50+28))
+}
+
+func TestPolyline_Do(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	black := color.RGBA{A: 0xff}
+
+	tx := newTestTexture(t)
+	ResetScreen(tx)
+	(&Line{X1: 10, Y1: 10, X2: 90, Y2: 10, C: red, Width: 1}).Do(tx)
+	img := tx.Image()
+	assert.Equal(t, red, img.RGBAAt(10, 10))
+	assert.Equal(t, red, img.RGBAAt(90, 10))
+	assert.Equal(t, black, img.RGBAAt(91, 10))
+	assert.Equal(t, black, img.RGBAAt(50, 11))
+
+	// Діагональ без розривів.
+	tx = newTestTexture(t)
+	ResetScreen(tx)
+	(&Line{X1: 0, Y1: 0, X2: 99, Y2: 99, C: red, Width: 1}).Do(tx)
+	img = tx.Image()
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, red, img.RGBAAt(i, i))
+	}
+
+	tx = newTestTexture(t)
+	ResetScreen(tx)
+	(&Polyline{Points: []image.Point{{20, 20}, {80, 20}, {80, 80}}, C: red, Width: 6}).Do(tx)
+	img = tx.Image()
+	assert.Equal(t, red, img.RGBAAt(50, 17))
+	assert.Equal(t, red, img.RGBAAt(50, 22))
+	assert.Equal(t, black, img.RGBAAt(50, 25))
+	assert.Equal(t, red, img.RGBAAt(82, 50))
+	assert.Equal(t, red, img.RGBAAt(82, 18), "join")
+	assert.Equal(t, black, img.RGBAAt(50, 50))
+
+	// Рядки за межами текстури не обробляються.
+	tx = newTestTexture(t)
+	c := &fillCounter{Texture: tx}
+	(&Line{X1: 50, Y1: -1e8, X2: 50, Y2: 1e8, C: red, Width: 6}).Do(c)
+	assert.Equal(t, 100, c.fills)
+	assert.Equal(t, red, tx.Image().RGBAAt(50, 0))
+	assert.Empty(t, diskSpans(image.Pt(-1e6, 50), 1e5, tx.Bounds()))
+}
+
+func TestPolygon_Do(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	black := color.RGBA{A: 0xff}
+	// Зірка з самоперетинами заповнюється повністю, включно з центром.
+	star := &Polygon{Points: []image.Point{{50, 5}, {76, 95}, {5, 35}, {95, 35}, {24, 95}}, C: red}
+
+	plain := newTestTexture(t)
+	ResetScreen(plain)
+	star.Do(plain)
+
+	// Через canvas багатокутник малюється у буфер і завантажується у текстуру через Upload.
+	tx := newTestTexture(t)
+	cv := newCanvas(tx, headless.Screen{})
+	ResetScreen(cv)
+	star.Do(cv)
+
+	for _, img := range []*image.RGBA{plain.Image(), tx.Image(), cv.img} {
+		assert.Equal(t, red, img.RGBAAt(50, 50))
+		assert.Equal(t, red, img.RGBAAt(50, 10))
+		assert.Equal(t, red, img.RGBAAt(10, 36))
+		assert.Equal(t, black, img.RGBAAt(50, 90))
+		assert.Equal(t, black, img.RGBAAt(5, 90))
+	}
+	assert.Equal(t, plain.Image(), tx.Image())
+
+	tx = newTestTexture(t)
+	ResetScreen(tx)
+	(&Polygon{Points: []image.Point{{10, 10}, {20, 10}, {20, 20}, {10, 20}}, C: red}).Do(tx)
+	img := tx.Image()
+	assert.Equal(t, red, img.RGBAAt(10, 10))
+	assert.Equal(t, red, img.RGBAAt(19, 19))
+	assert.Equal(t, black, img.RGBAAt(20, 15))
+	assert.Equal(t, black, img.RGBAAt(15, 20))
+
+	// Рядки за межами текстури не обробляються навіть для вершин з екстремальними координатами.
+	c := &fillCounter{Texture: tx}
+	(&Polygon{Points: []image.Point{{0, 0}, {800, 0}, {0, math.MinInt64}}, C: red}).Do(c)
+	assert.Zero(t, c.fills)
+	(&Polygon{Points: []image.Point{{-1e9, -1e9}, {1e9, -1e9}, {0, 1e9}}, C: red}).Do(c)
+	assert.Equal(t, 100, c.fills)
+	assert.Equal(t, red, tx.Image().RGBAAt(50, 99))
+}
+
+func TestText_Do(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	black := color.RGBA{A: 0xff}
+	label := &Text{X: 10, Y: 10, Size: 20, S: "HI", C: red}
+
+	count := func(img *image.RGBA) (n int) {
+		for y := 0; y < 100; y++ {
+			for x := 0; x < 100; x++ {
+				if c := img.RGBAAt(x, y); c != black {
+					assert.True(t, x >= 10 && y >= 10 && y < 30, "pixel (%d, %d) outside the label", x, y)
+					n++
+				}
+			}
+		}
+		return n
+	}
+
+	plain := newTestTexture(t)
+	ResetScreen(plain)
+	label.Do(plain)
+	assert.Greater(t, count(plain.Image()), 50)
+
+	// Через canvas краї гліфів згладжуються.
+	tx := newTestTexture(t)
+	cv := newCanvas(tx, headless.Screen{})
+	ResetScreen(cv)
+	label.Do(cv)
+	img := tx.Image()
+	assert.Greater(t, count(img), 50)
+	assert.Equal(t, cv.img, img)
+
+	// Маска великого тексту обмежується видимою частиною, а кеш шрифтів — maxCachedFaces.
+	huge := &Text{X: -10, Y: -10, Size: 40000, S: "W", C: red}
+	assert.False(t, huge.Do(cv))
+	for size := 1; size <= 2*maxCachedFaces; size++ {
+		(&Text{Size: size, S: "a"}).Do(plain)
+	}
+	facesMu.Lock()
+	assert.LessOrEqual(t, len(faces), maxCachedFaces)
+	facesMu.Unlock()
+}
+
+func TestImage_Do(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	black := color.RGBA{A: 0xff}
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(src, image.Rect(0, 0, 2, 4), &image.Uniform{C: red}, image.Point{}, draw.Src)
+
+	plain := newTestTexture(t)
+	ResetScreen(plain)
+	(&Image{X: 10, Y: 10, Img: src}).Do(plain)
+
+	tx := newTestTexture(t)
+	cv := newCanvas(tx, headless.Screen{})
+	ResetScreen(cv)
+	(&Image{X: 10, Y: 10, Img: src}).Do(cv)
+
+	// Прозора половина зображення не змінює фон.
+	for _, img := range []*image.RGBA{plain.Image(), tx.Image()} {
+		assert.Equal(t, red, img.RGBAAt(10, 10))
+		assert.Equal(t, red, img.RGBAAt(11, 13))
+		assert.Equal(t, black, img.RGBAAt(12, 10))
+		assert.Equal(t, black, img.RGBAAt(10, 14))
+	}
+
+	tx = newTestTexture(t)
+	ResetScreen(tx)
+	(&Image{X: 50, Y: 50, W: 40, H: 8, Img: src}).Do(tx)
+	img := tx.Image()
+	assert.Equal(t, red, img.RGBAAt(55, 57))
+	assert.Equal(t, black, img.RGBAAt(85, 57))
+	assert.Equal(t, black, img.RGBAAt(55, 58))
+
+	// Величезне зображення масштабується лише у межах текстури.
+	tx = newTestTexture(t)
+	(&Image{W: 1 << 20, H: 1 << 20, Img: src}).Do(tx)
+	assert.Equal(t, red, tx.Image().RGBAAt(99, 99))
+}
+
+func TestDrawOp_Over(t *testing.T) {
+	black := color.RGBA{A: 0xff}
+	halfRed := color.NRGBA{R: 0xff, A: 0x80}
+	blended := color.RGBA{R: 0x80, A: 0xff}
+
+	tx := newTestTexture(t)
+	ResetScreen(tx)
+	(&BgRectangle{X1: 0, Y1: 0, X2: 10, Y2: 10, C: halfRed}).Do(tx)
+	(&Figure{X: 50, Y: 50, Size: 30, C: color.RGBAModel.Convert(halfRed).(color.RGBA)}).Do(tx)
+	(&Polyline{Points: []image.Point{{10, 80}, {50, 80}, {50, 95}}, C: halfRed, Width: 6}).Do(tx)
+	img := tx.Image()
+
+	assert.Equal(t, blended, img.RGBAAt(5, 5))
+	assert.Equal(t, black, img.RGBAAt(15, 5))
+	// Місця з'єднання частин фігури та сегментів ламаної не змішуються двічі.
+	assert.Equal(t, blended, img.RGBAAt(50, 49))
+	assert.Equal(t, blended, img.RGBAAt(50, 50))
+	assert.Equal(t, blended, img.RGBAAt(50, 80))
+	assert.Equal(t, blended, img.RGBAAt(30, 80))
+
+	// Напівпрозоре зображення через буфер canvas.
+	src := image.NewUniform(color.RGBA{G: 0xff, A: 0xff})
+	tx = newTestTexture(t)
+	cv := newCanvas(tx, headless.Screen{})
+	ResetScreen(cv)
+	(&Image{X: 0, Y: 0, W: 10, H: 10, Img: image.NewRGBA(image.Rect(0, 0, 1, 1)), Opacity: 0.5}).Do(cv)
+	drawImage(cv, image.Rect(20, 20, 30, 30), src, 0.5)
+	assert.Equal(t, black, tx.Image().RGBAAt(5, 5))
+	assert.InDelta(t, 0x80, tx.Image().RGBAAt(25, 25).G, 1)
+}
+
+func TestGradient_Do(t *testing.T) {
+	linear := &LinearGradient{X1: 0, Y1: 0, X2: 100, Y2: 0, From: color.Black, To: color.White}
+	radial := &RadialGradient{X: 50, Y: 50, R: 20, From: color.White, To: color.Transparent}
+
+	plain := newTestTexture(t)
+	tx := newTestTexture(t)
+	for _, tt := range []screen.Texture{plain, newCanvas(tx, headless.Screen{})} {
+		(&PatternFill{P: linear}).Do(tt)
+		(&BgRectangle{X1: 30, Y1: 30, X2: 70, Y2: 70, Fill: radial}).Do(tt)
+	}
+
+	for _, img := range []*image.RGBA{plain.Image(), tx.Image()} {
+		assert.InDelta(t, 0, img.RGBAAt(0, 10).R, 1)
+		assert.InDelta(t, 0xff, img.RGBAAt(99, 10).R, 1)
+		assert.InDelta(t, 0x41, img.RGBAAt(25, 10).R, 1)
+		// Центр радіального градієнта майже непрозорий, а за радіусом залишається лінійний фон.
+		assert.InDelta(t, 0xff, img.RGBAAt(50, 50).R, 5)
+		assert.Equal(t, img.RGBAAt(30, 10), img.RGBAAt(30, 30))
+		assert.Equal(t, img.RGBAAt(69, 10), img.RGBAAt(69, 69))
+	}
+
+	// Заздалегідь намальовані градієнти дають ті ж пікселі з точністю до округлення.
+	rendered := newTestTexture(t)
+	cv := newCanvas(rendered, headless.Screen{})
+	(&PatternFill{P: RenderPattern(linear, image.Pt(100, 100))}).Do(cv)
+	(&BgRectangle{X1: 30, Y1: 30, X2: 70, Y2: 70, Fill: RenderPattern(radial, image.Pt(100, 100))}).Do(cv)
+	diff := 0
+	for i, v := range rendered.Image().Pix {
+		diff = max(diff, int(v)-int(tx.Image().Pix[i]), int(tx.Image().Pix[i])-int(v))
+	}
+	assert.LessOrEqual(t, diff, 1)
+}
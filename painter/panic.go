@@ -0,0 +1,54 @@
+package painter
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// PanicError описує паніку, яка виникла під час виконання операції в циклі подій.
+type PanicError struct {
+	Op    Operation // операція, яка викликала паніку
+	Value any       // значення, передане у panic
+	Stack []byte    // стек горутини на момент паніки
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("painter: operation %T panicked: %v", e.Op, e.Value)
+}
+
+// Unwrap повертає значення паніки, якщо воно є помилкою.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// exec виконує операцію над текстурою next, перехоплюючи паніку. Паніка повідомляється через report, а операція
+// вважається такою, що не підготувала кадр.
+func (l *Loop) exec(op Operation) (ready bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			ready = false
+			l.report(&PanicError{Op: op, Value: v, Stack: debug.Stack()})
+		}
+	}()
+	return op.Do(l.next)
+}
+
+// report передає помилку у OnError або записує її в журнал, якщо обробник не задано.
+func (l *Loop) report(err error) {
+	l.lastErr = err
+	if l.OnError != nil {
+		l.OnError(err)
+		return
+	}
+	log.Printf("%s\n%s", err, stackOf(err))
+}
+
+// stackOf повертає стек, збережений у PanicError.
+func stackOf(err error) []byte {
+	if pe, ok := err.(*PanicError); ok {
+		return pe.Stack
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package painter
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+)
+
+var (
+	// ErrQueueFull повертається, коли операцію не вдалося додати в заповнену чергу.
+	ErrQueueFull = errors.New("painter: message queue is full")
+	// ErrLoopStopped повертається при спробі додати операцію у чергу зупиненого циклу подій.
+	ErrLoopStopped = errors.New("painter: loop is stopped")
+)
+
+// OverflowPolicy визначає поведінку черги, коли в ній не залишилось місця для нової операції.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock блокує відправника, доки в черзі не звільниться місце.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowReject відхиляє нову операцію з помилкою ErrQueueFull.
+	OverflowReject
+	// OverflowDropOldest відкидає найстарішу операцію в черзі. Якщо в черзі немає операцій з пріоритетом, не вищим
+	// за пріоритет нової, нова операція відхиляється з помилкою ErrQueueFull.
+	OverflowDropOldest
+	// OverflowDropNonUpdate відкидає найстарішу операцію в черзі, яка не сигналізує про готовність кадру.
+	// Якщо таких операцій немає, нова операція відхиляється з помилкою ErrQueueFull.
+	OverflowDropNonUpdate
+)
+
+var overflowPolicyNames = []string{"block", "reject", "drop-oldest", "drop-non-update"}
+
+func (p OverflowPolicy) String() string {
+	if p < 0 || int(p) >= len(overflowPolicyNames) {
+		return "unknown"
+	}
+	return overflowPolicyNames[p]
+}
+
+// MarshalText дозволяє використовувати OverflowPolicy з flag.TextVar.
+func (p OverflowPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText розбирає назву політики: block, reject, drop-oldest або drop-non-update.
+func (p *OverflowPolicy) UnmarshalText(text []byte) error {
+	for i, name := range overflowPolicyNames {
+		if name == string(text) {
+			*p = OverflowPolicy(i)
+			return nil
+		}
+	}
+	return errors.New("unknown overflow policy: " + string(text))
+}
+
+// Priority визначає смугу черги, в яку потрапляє операція. Операції з вищим пріоритетом виконуються раніше
+// за операції з нижчим, а в межах однієї смуги зберігається порядок додавання.
+type Priority int
+
+const (
+	// PriorityLow для фонових операцій, які можна виконати після всіх інших.
+	PriorityLow Priority = iota - 1
+	// PriorityNormal пріоритет за замовчуванням.
+	PriorityNormal
+	// PriorityHigh для керуючих операцій, які мають випереджати накопичені операції малювання.
+	PriorityHigh
+)
+
+// numPriorities кількість смуг у черзі.
+const numPriorities = int(PriorityHigh-PriorityLow) + 1
+
+var priorityNames = []string{"low", "normal", "high"}
+
+func (p Priority) String() string {
+	if !p.valid() {
+		return "unknown"
+	}
+	return priorityNames[p-PriorityLow]
+}
+
+// MarshalText дозволяє використовувати Priority з flag.TextVar.
+func (p Priority) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText розбирає назву пріоритету: low, normal або high.
+func (p *Priority) UnmarshalText(text []byte) error {
+	for i, name := range priorityNames {
+		if name == string(text) {
+			*p = Priority(i) + PriorityLow
+			return nil
+		}
+	}
+	return errors.New("unknown priority: " + string(text))
+}
+
+func (p Priority) valid() bool {
+	return p >= PriorityLow && p <= PriorityHigh
+}
+
+// messageQueue реалізує чергу повідомлень з блокуванням
+type messageQueue struct {
+	// Capacity обмежує загальну кількість операцій у черзі. Нульове значення знімає обмеження.
+	Capacity int
+	// Policy визначає, що відбувається з новою операцією, коли черга заповнена. Операції відкидаються,
+	// починаючи зі смуги з найнижчим пріоритетом, але лише зі смуг, пріоритет яких не вищий за пріоритет нової
+	// операції.
+	Policy OverflowPolicy
+
+	lanes  [numPriorities][]Operation // смуги черги, впорядковані за зростанням пріоритету
+	size   int                        // загальна кількість операцій у смугах
+	mu     sync.Mutex
+	notify chan struct{} // сигналізує про появу нових операцій у черзі
+	space  chan struct{} // закривається, коли в заповненій черзі звільняється місце
+	closed bool          // черга не приймає нових операцій
+}
+
+// Push додає операцію в чергу зі звичайним пріоритетом. Якщо черга заповнена, поведінка визначається Policy.
+func (MsgQueue *messageQueue) Push(op Operation) error {
+	return MsgQueue.push(context.Background(), op, PriorityNormal)
+}
+
+// push додає операцію в смугу з пріоритетом prio, очікуючи на вільне місце не довше, ніж до скасування ctx.
+func (MsgQueue *messageQueue) push(ctx context.Context, op Operation, prio Priority) error {
+	if !prio.valid() {
+		return errors.New("painter: invalid priority " + strconv.Itoa(int(prio)))
+	}
+
+	MsgQueue.mu.Lock()
+	defer MsgQueue.mu.Unlock()
+
+	lane := int(prio - PriorityLow)
+	for {
+		if MsgQueue.closed {
+			return ErrLoopStopped
+		}
+		if MsgQueue.Capacity <= 0 || MsgQueue.size < MsgQueue.Capacity {
+			break
+		}
+
+		switch MsgQueue.Policy {
+		case OverflowReject:
+			return ErrQueueFull
+		case OverflowDropOldest:
+			l := MsgQueue.oldest(lane)
+			if l < 0 {
+				return ErrQueueFull
+			}
+			dropped(MsgQueue.lanes[l][0], ErrOperationDropped)
+			MsgQueue.remove(l, 0)
+		case OverflowDropNonUpdate:
+			l, i := MsgQueue.oldestNonUpdate(lane)
+			if i < 0 {
+				return ErrQueueFull
+			}
+			dropped(MsgQueue.lanes[l][i], ErrOperationDropped)
+			MsgQueue.remove(l, i)
+		default:
+			if MsgQueue.space == nil {
+				MsgQueue.space = make(chan struct{})
+			}
+			space := MsgQueue.space
+			MsgQueue.mu.Unlock()
+			select {
+			case <-space:
+			case <-ctx.Done():
+				MsgQueue.mu.Lock()
+				return ctx.Err()
+			}
+			MsgQueue.mu.Lock()
+		}
+	}
+
+	MsgQueue.lanes[lane] = append(MsgQueue.lanes[lane], op)
+	MsgQueue.size++
+	MsgQueue.signal()
+	return nil
+}
+
+// Pull витягає наступну операцію з черги (блокуюча операція)
+func (MsgQueue *messageQueue) Pull() Operation {
+	for {
+		if op, ok := MsgQueue.tryPull(); ok {
+			return op
+		}
+		<-MsgQueue.ready()
+	}
+}
+
+// tryPull витягає наступну операцію зі смуги з найвищим пріоритетом, якщо вона є, не блокуючись.
+func (MsgQueue *messageQueue) tryPull() (Operation, bool) {
+	MsgQueue.mu.Lock()
+	defer MsgQueue.mu.Unlock()
+
+	for lane := len(MsgQueue.lanes) - 1; lane >= 0; lane-- {
+		if len(MsgQueue.lanes[lane]) > 0 {
+			op := MsgQueue.lanes[lane][0]
+			MsgQueue.remove(lane, 0)
+			return op, true
+		}
+	}
+	return nil, false
+}
+
+// remove видаляє операцію з індексом i у смузі lane і будить відправників, які чекають на місце.
+// Викликається під захистом mu.
+func (MsgQueue *messageQueue) remove(lane, i int) {
+	q := MsgQueue.lanes[lane]
+	if i == 0 {
+		q[0] = nil
+		q = q[1:]
+	} else {
+		copy(q[i:], q[i+1:])
+		q[len(q)-1] = nil
+		q = q[:len(q)-1]
+	}
+	MsgQueue.lanes[lane] = q
+	MsgQueue.size--
+	MsgQueue.freeSpace()
+}
+
+// oldest повертає непорожню смугу з найнижчим пріоритетом серед смуг до maxLane включно або -1, якщо такої немає.
+func (MsgQueue *messageQueue) oldest(maxLane int) int {
+	for lane := 0; lane <= maxLane; lane++ {
+		if len(MsgQueue.lanes[lane]) > 0 {
+			return lane
+		}
+	}
+	return -1
+}
+
+// oldestNonUpdate повертає смугу та індекс найстарішої операції з найнижчим пріоритетом серед смуг до maxLane
+// включно, яка не сигналізує про готовність кадру, або індекс -1, якщо таких операцій немає.
+func (MsgQueue *messageQueue) oldestNonUpdate(maxLane int) (int, int) {
+	for lane := 0; lane <= maxLane; lane++ {
+		for i, op := range MsgQueue.lanes[lane] {
+			if !isUpdate(op) {
+				return lane, i
+			}
+		}
+	}
+	return 0, -1
+}
+
+// isUpdate перевіряє, чи містить операція UpdateOp.
+func isUpdate(op Operation) bool {
+	switch op := op.(type) {
+	case updateOp:
+		return true
+	case OperationList:
+		for _, o := range op {
+			if isUpdate(o) {
+				return true
+			}
+		}
+	case *Scheduled:
+		return isUpdate(op.Op)
+	case *tracked:
+		return isUpdate(op.op)
+	}
+	return false
+}
+
+// ready повертає канал, з якого можна прочитати після додавання нових операцій у чергу.
+// Сигнал може бути хибним, тому після його отримання чергу потрібно перевірити ще раз.
+func (MsgQueue *messageQueue) ready() <-chan struct{} {
+	MsgQueue.mu.Lock()
+	defer MsgQueue.mu.Unlock()
+	return MsgQueue.notifyChan()
+}
+
+func (MsgQueue *messageQueue) notifyChan() chan struct{} {
+	if MsgQueue.notify == nil {
+		MsgQueue.notify = make(chan struct{}, 1)
+	}
+	return MsgQueue.notify
+}
+
+// signal будить читача черги. Викликається під захистом mu.
+func (MsgQueue *messageQueue) signal() {
+	select {
+	case MsgQueue.notifyChan() <- struct{}{}:
+	default:
+	}
+}
+
+// freeSpace будить відправників, які чекають на місце в черзі. Викликається під захистом mu.
+func (MsgQueue *messageQueue) freeSpace() {
+	if MsgQueue.space != nil {
+		close(MsgQueue.space)
+		MsgQueue.space = nil
+	}
+}
+
+func (MsgQueue *messageQueue) Size() int {
+	MsgQueue.mu.Lock()
+	defer MsgQueue.mu.Unlock()
+	return MsgQueue.size
+}
+
+// open дозволяє додавати операції в чергу.
+func (MsgQueue *messageQueue) open() {
+	MsgQueue.mu.Lock()
+	defer MsgQueue.mu.Unlock()
+	MsgQueue.closed = false
+}
+
+// close забороняє додавати нові операції в чергу і будить відправників, які чекають на місце.
+func (MsgQueue *messageQueue) close() {
+	MsgQueue.mu.Lock()
+	defer MsgQueue.mu.Unlock()
+	MsgQueue.closed = true
+	MsgQueue.freeSpace()
+}
+
+func (MsgQueue *messageQueue) Clear() {
+	MsgQueue.mu.Lock()
+	defer MsgQueue.mu.Unlock()
+	for _, q := range MsgQueue.lanes {
+		for _, op := range q {
+			dropped(op, ErrLoopStopped)
+		}
+	}
+	MsgQueue.lanes = [numPriorities][]Operation{}
+	MsgQueue.size = 0
+	MsgQueue.freeSpace()
+}
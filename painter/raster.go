@@ -0,0 +1,253 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/draw"
+)
+
+// rasterTarget текстура, яка має сумісний буфер свого розміру і дозволяє прочитати поточний вміст. Її реалізує
+// canvas циклу подій.
+type rasterTarget interface {
+	screen.Texture
+	buffer() (screen.Buffer, error)
+	pixels() *image.RGBA
+}
+
+// span відрізок рядка пікселів y від x1 включно до x2.
+type span struct {
+	y, x1, x2 int
+}
+
+// withBuffer копіює область clip текстури у її буфер, викликає fn, щоб намалювати поверх цієї області,
+// і завантажує результат у текстуру одним викликом Upload. Зображення dst обмежене областю clip і має ті ж
+// координати, що й текстура. Повертає false, якщо текстура не має буфера і малювати потрібно через Fill.
+func withBuffer(t screen.Texture, clip image.Rectangle, fn func(dst *image.RGBA)) bool {
+	rt, ok := t.(rasterTarget)
+	if !ok {
+		return false
+	}
+	buf, err := rt.buffer()
+	if err != nil {
+		return false
+	}
+	dst := buf.RGBA().SubImage(clip).(*image.RGBA)
+	draw.Draw(dst, clip, rt.pixels(), clip.Min, draw.Src)
+	fn(dst)
+	rt.Upload(clip.Min, buf, clip)
+	return true
+}
+
+// drawSpans зафарбовує відрізки spans кольором c з режимом op через withBuffer, а якщо це неможливо — кожен
+// відрізок окремо через Fill.
+func drawSpans(t screen.Texture, spans []span, c color.Color, op draw.Op) {
+	var r image.Rectangle
+	for _, s := range spans {
+		r = r.Union(image.Rect(s.x1, s.y, s.x2, s.y+1))
+	}
+	if r = r.Intersect(t.Bounds()); r.Empty() {
+		return
+	}
+
+	src := &image.Uniform{C: c}
+	if withBuffer(t, r, func(dst *image.RGBA) {
+		for _, s := range spans {
+			draw.Draw(dst, image.Rect(s.x1, s.y, s.x2, s.y+1), src, image.Point{}, op)
+		}
+	}) {
+		return
+	}
+	for _, s := range spans {
+		t.Fill(image.Rect(s.x1, s.y, s.x2, s.y+1), c, op)
+	}
+}
+
+// drawMask зафарбовує кольором c пікселі прямокутника r з урахуванням маски mask, точка r.Min якої відповідає mp.
+// Через withBuffer маска накладається зі згладжуванням, інакше зафарбовуються лише пікселі, покриті маскою
+// щонайменше наполовину.
+func drawMask(t screen.Texture, r image.Rectangle, mask image.Image, mp image.Point, c color.Color) {
+	clip := r.Intersect(t.Bounds())
+	if clip.Empty() {
+		return
+	}
+	mp = mp.Add(clip.Min.Sub(r.Min))
+
+	if withBuffer(t, clip, func(dst *image.RGBA) {
+		draw.DrawMask(dst, clip, &image.Uniform{C: c}, image.Point{}, mask, mp, draw.Over)
+	}) {
+		return
+	}
+
+	var spans []span
+	for y := 0; y < clip.Dy(); y++ {
+		start := -1
+		for x := 0; x <= clip.Dx(); x++ {
+			covered := false
+			if x < clip.Dx() {
+				_, _, _, a := mask.At(mp.X+x, mp.Y+y).RGBA()
+				covered = a >= 0x8000
+			}
+			switch {
+			case covered && start < 0:
+				start = x
+			case !covered && start >= 0:
+				spans = append(spans, span{y: clip.Min.Y + y, x1: clip.Min.X + start, x2: clip.Min.X + x})
+				start = -1
+			}
+		}
+	}
+	drawSpans(t, spans, c, drawOp(c))
+}
+
+// drawImage масштабує зображення src у прямокутник r і накладає його на текстуру з урахуванням прозорості
+// та непрозорості opacity. Без withBuffer кожен непрозорий піксель зафарбовується окремо через Fill.
+func drawImage(t screen.Texture, r image.Rectangle, src image.Image, opacity float64) {
+	clip := r.Intersect(t.Bounds())
+	if clip.Empty() {
+		return
+	}
+	var opts *draw.Options
+	if opacity < 1 {
+		opts = &draw.Options{SrcMask: image.NewUniform(color.Alpha16{A: uint16(opacity * 0xffff)})}
+	}
+
+	if withBuffer(t, clip, func(dst *image.RGBA) {
+		draw.ApproxBiLinear.Scale(dst, r, src, src.Bounds(), draw.Over, opts)
+	}) {
+		return
+	}
+
+	// Масштабується лише видима частина зображення.
+	scaled := image.NewRGBA(clip)
+	draw.ApproxBiLinear.Scale(scaled, r, src, src.Bounds(), draw.Src, opts)
+	for y := clip.Min.Y; y < clip.Max.Y; y++ {
+		for x := clip.Min.X; x < clip.Max.X; x++ {
+			if c := scaled.RGBAAt(x, y); c.A > 0 {
+				t.Fill(image.Rect(x, y, x+1, y+1), c, draw.Over)
+			}
+		}
+	}
+}
+
+// mergeSpans об'єднує відрізки, які перекриваються або стикуються, щоб кожен піксель зафарбовувався лише один раз.
+func mergeSpans(spans []span) []span {
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].y != spans[j].y {
+			return spans[i].y < spans[j].y
+		}
+		return spans[i].x1 < spans[j].x1
+	})
+	var res []span
+	for _, s := range spans {
+		if n := len(res); n > 0 && res[n-1].y == s.y && s.x1 <= res[n-1].x2 {
+			res[n-1].x2 = max(res[n-1].x2, s.x2)
+			continue
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// drawPattern накладає на прямокутник r текстури зображення src з тими ж координатами з режимом op. Без withBuffer
+// кожен піксель зафарбовується окремо через Fill.
+func drawPattern(t screen.Texture, r image.Rectangle, src image.Image, op draw.Op) {
+	clip := r.Intersect(t.Bounds())
+	if clip.Empty() {
+		return
+	}
+
+	if withBuffer(t, clip, func(dst *image.RGBA) {
+		draw.Draw(dst, clip, src, clip.Min, op)
+	}) {
+		return
+	}
+
+	for y := clip.Min.Y; y < clip.Max.Y; y++ {
+		for x := clip.Min.X; x < clip.Max.X; x++ {
+			t.Fill(image.Rect(x, y, x+1, y+1), src.At(x, y), op)
+		}
+	}
+}
+
+// polygonSpans розбиває багатокутник pts на відрізки рядків пікселів у межах clip. Піксель належить багатокутнику,
+// якщо його центр лежить всередині за правилом ненульового числа обертів, тож самоперетини (як у зірки) теж
+// заповнюються.
+func polygonSpans(pts []image.Point, clip image.Rectangle) []span {
+	if len(pts) < 3 {
+		return nil
+	}
+	minY, maxY := pts[0].Y, pts[0].Y
+	for _, p := range pts {
+		minY, maxY = min(minY, p.Y), max(maxY, p.Y)
+	}
+
+	type crossing struct {
+		x   float64
+		dir int
+	}
+	var (
+		spans []span
+		xs    []crossing
+	)
+	for y := max(minY, clip.Min.Y); y < min(maxY, clip.Max.Y); y++ {
+		cy := float64(y) + 0.5
+		xs = xs[:0]
+		for i := range pts {
+			p, q := pts[i], pts[(i+1)%len(pts)]
+			py, qy := float64(p.Y), float64(q.Y)
+			if (cy < py) == (cy < qy) {
+				continue
+			}
+			dir := 1
+			if qy < py {
+				dir = -1
+			}
+			x := float64(p.X) + (cy-py)*float64(q.X-p.X)/(qy-py)
+			xs = append(xs, crossing{x: x, dir: dir})
+		}
+		sort.Slice(xs, func(i, j int) bool { return xs[i].x < xs[j].x })
+
+		winding := 0
+		for i, c := range xs {
+			winding += c.dir
+			if winding == 0 || i+1 == len(xs) {
+				continue
+			}
+			// Заповнюємо пікселі, центри яких лежать між сусідніми перетинами.
+			x1 := math.Max(c.x, float64(clip.Min.X))
+			x2 := math.Min(xs[i+1].x, float64(clip.Max.X))
+			if x1 >= x2 {
+				continue
+			}
+			l, r := int(math.Ceil(x1-0.5)), int(math.Floor(x2-0.5))+1
+			if r <= l {
+				continue
+			}
+			if n := len(spans); n > 0 && spans[n-1].y == y && spans[n-1].x2 == l {
+				spans[n-1].x2 = r
+				continue
+			}
+			spans = append(spans, span{y: y, x1: l, x2: r})
+		}
+	}
+	return spans
+}
+
+// Polygon заповнює багатокутник з вершинами Points кольором C (чорним, якщо колір не задано).
+type Polygon struct {
+	Points []image.Point
+	C      color.Color
+}
+
+func (op *Polygon) Do(t screen.Texture) bool {
+	c := op.C
+	if c == nil {
+		c = color.Black
+	}
+	drawSpans(t, polygonSpans(op.Points, t.Bounds()), c, drawOp(c))
+	return false
+}
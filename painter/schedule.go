@@ -0,0 +1,99 @@
+package painter
+
+import (
+	"container/heap"
+	"time"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Scheduled відкладає виконання операції Op до моменту At. Цикл подій тримає такі операції у власній черзі
+// таймерів і виконує їх, коли настане час, у тому числі якщо Scheduled входить до OperationList. Поза циклом
+// подій Op виконується одразу.
+type Scheduled struct {
+	At time.Time
+	Op Operation
+}
+
+func (op *Scheduled) Do(t screen.Texture) bool {
+	return op.Op.Do(t)
+}
+
+// PostAt додає операцію, яку цикл подій виконає в момент at. Операції з однаковим часом виконуються в порядку
+// додавання. Відкладені операції, час яких не настав до зупинки циклу, відкидаються.
+func (l *Loop) PostAt(op Operation, at time.Time) error {
+	if op == nil {
+		return nil
+	}
+	return l.Post(&Scheduled{At: at, Op: op})
+}
+
+// PostAfter додає операцію, яку цикл подій виконає через d після виклику.
+func (l *Loop) PostAfter(op Operation, d time.Duration) error {
+	return l.PostAt(op, time.Now().Add(d))
+}
+
+// timer відкладена операція у черзі таймерів.
+type timer struct {
+	at  time.Time
+	seq uint64 // порядковий номер, який зберігає порядок додавання операцій з однаковим часом
+	op  Operation
+}
+
+// timerHeap черга таймерів, впорядкована за часом виконання. Використовується лише з горутини циклу подій.
+type timerHeap struct {
+	timers []timer
+	seq    uint64
+}
+
+func (h *timerHeap) Len() int { return len(h.timers) }
+
+func (h *timerHeap) Less(i, j int) bool {
+	a, b := h.timers[i], h.timers[j]
+	if a.at.Equal(b.at) {
+		return a.seq < b.seq
+	}
+	return a.at.Before(b.at)
+}
+
+func (h *timerHeap) Swap(i, j int) { h.timers[i], h.timers[j] = h.timers[j], h.timers[i] }
+
+func (h *timerHeap) Push(x any) { h.timers = append(h.timers, x.(timer)) }
+
+func (h *timerHeap) Pop() any {
+	n := len(h.timers) - 1
+	t := h.timers[n]
+	h.timers[n] = timer{}
+	h.timers = h.timers[:n]
+	return t
+}
+
+// schedule додає операцію op, яку потрібно виконати в момент at.
+func (h *timerHeap) schedule(at time.Time, op Operation) {
+	h.seq++
+	heap.Push(h, timer{at: at, seq: h.seq, op: op})
+}
+
+// next повертає час найближчого таймера.
+func (h *timerHeap) next() (time.Time, bool) {
+	if len(h.timers) == 0 {
+		return time.Time{}, false
+	}
+	return h.timers[0].at, true
+}
+
+// due витягає найближчу операцію, якщо її час вже настав на момент now.
+func (h *timerHeap) due(now time.Time) (Operation, bool) {
+	if len(h.timers) == 0 || h.timers[0].at.After(now) {
+		return nil, false
+	}
+	return heap.Pop(h).(timer).op, true
+}
+
+// clear відкидає всі таймери, завершуючи Future відстежуваних операцій з помилкою err.
+func (h *timerHeap) clear(err error) {
+	for _, t := range h.timers {
+		dropped(t.op, err)
+	}
+	h.timers = nil
+}
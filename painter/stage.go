@@ -0,0 +1,75 @@
+package painter
+
+import (
+	"image"
+
+	"golang.org/x/exp/shiny/screen"
+)
+
+// Stage зберігає сцену, яку останньою почав малювати цикл подій, та зміщення фігур, що анімуються. Кадри анімацій
+// перемальовують саме цю сцену, тож зміни, надіслані під час анімації, не губляться. Стан Stage змінюють лише
+// операції, які виконуються у циклі подій.
+type Stage struct {
+	scene   OperationList
+	offsets map[string]image.Point
+}
+
+// Enter повертає операцію, яка робить scene поточною сценою і зсуває її фігури на зміщення анімацій, що ще
+// виконуються. Операція має виконуватися перед операціями scene.
+func (s *Stage) Enter(scene ...Operation) Operation {
+	return &enterStage{stage: s, scene: scene}
+}
+
+type enterStage struct {
+	stage *Stage
+	scene OperationList
+}
+
+func (op *enterStage) Do(t screen.Texture) bool {
+	op.stage.scene = op.scene
+	for id, d := range op.stage.offsets {
+		op.stage.shift(id, d)
+	}
+	return false
+}
+
+// offset змінює зміщення фігур з ідентифікатором id на d і зсуває їх у поточній сцені.
+func (s *Stage) offset(id string, d image.Point) {
+	if s.offsets == nil {
+		s.offsets = make(map[string]image.Point)
+	}
+	if o := s.offsets[id].Add(d); o == (image.Point{}) {
+		delete(s.offsets, id)
+	} else {
+		s.offsets[id] = o
+	}
+	s.shift(id, d)
+}
+
+// shift зсуває фігури поточної сцени з ідентифікатором id на d.
+func (s *Stage) shift(id string, d image.Point) {
+	for _, op := range s.scene {
+		if fig, ok := op.(*Figure); ok && fig.ID == id {
+			fig.X += d.X
+			fig.Y += d.Y
+		}
+	}
+}
+
+// stageOffset операція, яка змінює зміщення фігур id на d і, якщо redraw встановлено, перемальовує поточну сцену
+// та сигналізує про готовність текстури.
+type stageOffset struct {
+	stage  *Stage
+	id     string
+	d      image.Point
+	redraw bool
+}
+
+func (op *stageOffset) Do(t screen.Texture) bool {
+	op.stage.offset(op.id, op.d)
+	if !op.redraw {
+		return false
+	}
+	op.stage.scene.Do(t)
+	return true
+}
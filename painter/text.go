@@ -0,0 +1,113 @@
+package painter
+
+import (
+	"image"
+	"image/color"
+	"sync"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Text малює рядок S шрифтом Go Regular висотою Size пікселів. (X, Y) задають лівий верхній кут тексту.
+type Text struct {
+	X, Y int
+	Size int
+	S    string
+	C    color.Color
+}
+
+// defaultTextSize висота тексту, якщо Size не задано.
+const defaultTextSize = 16
+
+// MaxTextSize найбільша висота тексту у пікселях. Більші значення Size зменшуються до неї.
+const MaxTextSize = 1024
+
+// maxCachedFaces кількість шрифтів різної висоти, які зберігаються між викликами.
+const maxCachedFaces = 16
+
+func (op *Text) Do(t screen.Texture) bool {
+	if op.S == "" {
+		return false
+	}
+	c := op.C
+	if c == nil {
+		c = color.Black
+	}
+	size := op.Size
+	if size <= 0 {
+		size = defaultTextSize
+	}
+	size = min(size, MaxTextSize)
+
+	// Малюємо гліфи у маску, яку потім накладаємо на текстуру. Маска покриває лише видиму частину тексту.
+	pos := image.Pt(op.X, op.Y)
+	var (
+		mask *image.Alpha
+		r    image.Rectangle
+	)
+	err := withFace(size, func(face font.Face) {
+		origin := fixed.P(0, face.Metrics().Ascent.Ceil())
+		bounds, _ := font.BoundString(face, op.S)
+		bounds = bounds.Add(origin)
+		r = image.Rect(bounds.Min.X.Floor(), bounds.Min.Y.Floor(), bounds.Max.X.Ceil(), bounds.Max.Y.Ceil())
+		r = r.Intersect(t.Bounds().Sub(pos))
+		if r.Empty() {
+			return
+		}
+		mask = image.NewAlpha(r)
+		d := font.Drawer{Dst: mask, Src: image.Opaque, Face: face, Dot: origin}
+		d.DrawString(op.S)
+	})
+	if err != nil || mask == nil {
+		return false
+	}
+
+	drawMask(t, r.Add(pos), mask, r.Min, c)
+	return false
+}
+
+var (
+	fontOnce sync.Once
+	fontData *opentype.Font
+	fontErr  error
+
+	facesMu sync.Mutex
+	faces   = map[int]font.Face{} // шрифти, підготовлені для кожної висоти тексту
+)
+
+// withFace викликає fn з вбудованим шрифтом висотою size пікселів. Шрифти не можна використовувати з кількох
+// горутин одночасно, тож fn виконується під захистом facesMu. Кеш зберігає не більше maxCachedFaces шрифтів,
+// тож при переповненні довільний з них відкидається.
+func withFace(size int, fn func(face font.Face)) error {
+	fontOnce.Do(func() {
+		fontData, fontErr = opentype.Parse(goregular.TTF)
+	})
+	if fontErr != nil {
+		return fontErr
+	}
+
+	facesMu.Lock()
+	defer facesMu.Unlock()
+	face, ok := faces[size]
+	if !ok {
+		var err error
+		face, err = opentype.NewFace(fontData, &opentype.FaceOptions{Size: float64(size), DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return err
+		}
+		if len(faces) >= maxCachedFaces {
+			for k, f := range faces {
+				f.Close()
+				delete(faces, k)
+				break
+			}
+		}
+		faces[size] = face
+	}
+	fn(face)
+	return nil
+}
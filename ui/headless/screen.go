@@ -0,0 +1,122 @@
+// Package headless містить реалізацію screen.Screen, яка працює повністю в пам'яті без вікна.
+// Вона дозволяє запускати painter.Loop на машинах без дисплею та перевіряти отримані пікселі.
+package headless
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"sync"
+
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/image/draw"
+)
+
+// ErrNoWindows повертається при спробі створити вікно на екрані без дисплею.
+var ErrNoWindows = errors.New("headless: windows are not supported")
+
+// Screen реалізує screen.Screen, створюючи буфери та текстури на базі image.RGBA.
+type Screen struct{}
+
+// NewBuffer створює буфер заданого розміру.
+func (Screen) NewBuffer(size image.Point) (screen.Buffer, error) {
+	return &Buffer{rgba: image.NewRGBA(image.Rectangle{Max: size})}, nil
+}
+
+// NewTexture створює текстуру заданого розміру.
+func (Screen) NewTexture(size image.Point) (screen.Texture, error) {
+	return &Texture{rgba: image.NewRGBA(image.Rectangle{Max: size})}, nil
+}
+
+// NewWindow завжди повертає ErrNoWindows.
+func (Screen) NewWindow(opts *screen.NewWindowOptions) (screen.Window, error) {
+	return nil, ErrNoWindows
+}
+
+// Buffer реалізує screen.Buffer поверх image.RGBA.
+type Buffer struct {
+	rgba *image.RGBA
+}
+
+func (b *Buffer) Release()                {}
+func (b *Buffer) Size() image.Point       { return b.rgba.Rect.Size() }
+func (b *Buffer) Bounds() image.Rectangle { return b.rgba.Rect }
+func (b *Buffer) RGBA() *image.RGBA       { return b.rgba }
+
+// Texture реалізує screen.Texture поверх image.RGBA.
+type Texture struct {
+	mu   sync.Mutex
+	rgba *image.RGBA
+}
+
+func (t *Texture) Release()                {}
+func (t *Texture) Size() image.Point       { return t.rgba.Rect.Size() }
+func (t *Texture) Bounds() image.Rectangle { return t.rgba.Rect }
+
+// Upload копіює частину sr буфера src у текстуру, починаючи з точки dp.
+func (t *Texture) Upload(dp image.Point, src screen.Buffer, sr image.Rectangle) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	draw.Draw(t.rgba, sr.Sub(sr.Min).Add(dp), src.RGBA(), sr.Min, draw.Src)
+}
+
+// Fill зафарбовує прямокутник dr кольором src.
+func (t *Texture) Fill(dr image.Rectangle, src color.Color, op draw.Op) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	draw.Draw(t.rgba, dr, &image.Uniform{C: src}, image.Point{}, op)
+}
+
+// Image повертає копію поточного вмісту текстури.
+func (t *Texture) Image() *image.RGBA {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	img := image.NewRGBA(t.rgba.Rect)
+	copy(img.Pix, t.rgba.Pix)
+	return img
+}
+
+// Recorder реалізує painter.Receiver і зберігає копію останнього отриманого кадру.
+type Recorder struct {
+	mu    sync.Mutex
+	frame *image.RGBA
+}
+
+// Update запам'ятовує вміст текстури. Текстури, створені не через Screen, ігноруються.
+func (r *Recorder) Update(t screen.Texture) {
+	ht, ok := t.(*Texture)
+	if !ok {
+		return
+	}
+	img := ht.Image()
+
+	r.mu.Lock()
+	r.frame = img
+	r.mu.Unlock()
+}
+
+// Frame повертає останній отриманий кадр або nil, якщо кадрів ще не було.
+func (r *Recorder) Frame() *image.RGBA {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.frame
+}
+
+// SavePNG записує останній отриманий кадр у файл формату PNG.
+func (r *Recorder) SavePNG(path string) error {
+	frame := r.Frame()
+	if frame == nil {
+		return errors.New("headless: no frame has been received yet")
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, frame); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,55 @@
+package headless_test
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sifes/kpi-3-lab3/painter"
+	"github.com/sifes/kpi-3-lab3/painter/lang"
+	"github.com/sifes/kpi-3-lab3/ui/headless"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/draw"
+)
+
+func TestTexture_FillAndUpload(t *testing.T) {
+	var s headless.Screen
+	tx, _ := s.NewTexture(image.Pt(10, 10))
+	tx.Fill(tx.Bounds(), color.White, draw.Src)
+
+	buf, _ := s.NewBuffer(image.Pt(2, 2))
+	draw.Draw(buf.RGBA(), buf.Bounds(), &image.Uniform{C: color.Black}, image.Point{}, draw.Src)
+	tx.Upload(image.Pt(4, 4), buf, buf.Bounds())
+
+	img := tx.(*headless.Texture).Image()
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, img.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{A: 0xff}, img.RGBAAt(5, 5))
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, img.RGBAAt(6, 6))
+
+	_, err := s.NewWindow(nil)
+	assert.ErrorIs(t, err, headless.ErrNoWindows)
+}
+
+func TestPipeline(t *testing.T) {
+	var (
+		l      painter.Loop
+		p      lang.Parser
+		rec    headless.Recorder
+		screen headless.Screen
+	)
+	l.Receiver = &rec
+	l.Start(screen)
+
+	ops, err := p.Parse(strings.NewReader("green\nbgrect 0.25 0.25 0.75 0.75\nupdate"))
+	assert.NoError(t, err)
+	l.Post(painter.OperationList(ops))
+
+	assert.Eventually(t, func() bool { return rec.Frame() != nil }, time.Second, 10*time.Millisecond)
+	l.StopAndWait()
+
+	frame := rec.Frame()
+	assert.Equal(t, color.RGBA{G: 0xff, A: 0xff}, frame.RGBAAt(10, 10))
+	assert.Equal(t, color.RGBA{A: 0xff}, frame.RGBAAt(400, 400))
+}
@@ -21,6 +21,9 @@ type Visualizer struct {
 	Debug         bool
 	OnScreenReady func(s screen.Screen)
 
+	// Width та Height задають початковий розмір вікна. Нульові значення означають 800.
+	Width, Height int
+
 	w    screen.Window
 	tx   chan screen.Texture
 	done chan struct{}
@@ -39,14 +42,25 @@ func (pw *Visualizer) Main() {
 }
 
 func (pw *Visualizer) Update(t screen.Texture) {
-	pw.tx <- t
+	// Після закриття вікна текстури більше ніхто не читає.
+	select {
+	case pw.tx <- t:
+	case <-pw.done:
+	}
 }
 
 func (pw *Visualizer) run(s screen.Screen) {
+	width, height := pw.Width, pw.Height
+	if width <= 0 {
+		width = 800
+	}
+	if height <= 0 {
+		height = 800
+	}
 	w, err := s.NewWindow(&screen.NewWindowOptions{
 		Title:  pw.Title,
-		Width:  800,
-		Height: 800,
+		Width:  width,
+		Height: height,
 	})
 	if err != nil {
 		log.Fatal("Failed to initialize the app window:", err)
@@ -141,7 +155,8 @@ func (pw *Visualizer) drawDefaultUI() {
 
 	var centerX, centerY int
 	if pw.mousePos == (image.Point{}) {
-		centerX, centerY = 400, 400
+		c := pw.sz.Bounds().Max.Div(2)
+		centerX, centerY = c.X, c.Y
 	} else {
 		centerX, centerY = pw.mousePos.X, pw.mousePos.Y
 	}